@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/NaiKiDEV/ssh-chat/internal/audit"
+	"github.com/charmbracelet/ssh"
+)
+
+// Announcements are shown in the header banner, keep them short
+const maxAnnouncementSize = 280
+
+// Chat command admins announce with from an interactive session
+const announceCommand = "announce"
+
+var errNotAdmin = errors.New("only admins can make announcements")
+
+// Admins are recognized by the fingerprint of the key they authenticated
+// with, see verifiedFingerprint
+func isAdmin(fingerprint string) bool {
+	return fingerprint != "" && slices.Contains(serverState.Config().Admins, fingerprint)
+}
+
+// Pushes text to every interactive session and IRC client regardless of the
+// room they are in
+func announce(sessionId string, userName string, remoteAddr string, fingerprint string, text string) error {
+	event := audit.Event{
+		Type:        audit.TypeModeration,
+		SessionId:   sessionId,
+		User:        userName,
+		RemoteAddr:  remoteAddr,
+		Fingerprint: fingerprint,
+		Action:      "announce",
+		Details:     map[string]string{"text": text},
+	}
+	if !isAdmin(fingerprint) {
+		event.Result = audit.ResultRejected
+		auditLog.Log(event)
+		return errNotAdmin
+	}
+
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return errors.New("announcement is empty")
+	}
+	if utf8.RuneCountInString(text) > maxAnnouncementSize {
+		return fmt.Errorf("announcement is longer than %d characters", maxAnnouncementSize)
+	}
+
+	notice := serverNotice{
+		Kind: noticeAnnouncement,
+		Text: text,
+		From: userName,
+	}
+	serverState.notices.Broadcast(notice)
+	if serverState.irc != nil {
+		serverState.irc.Notice(formatAnnouncement(notice))
+	}
+
+	event.Result = audit.ResultOk
+	auditLog.Log(event)
+	return nil
+}
+
+func formatAnnouncement(notice serverNotice) string {
+	return fmt.Sprintf("Announcement from %s: %s", notice.From, notice.Text)
+}
+
+func runAnnounceCommand(s ssh.Session, args []string) error {
+	text, err := readMessageArgs(s, args)
+	if err != nil {
+		return err
+	}
+
+	return announce(s.Context().SessionID(), s.User(), s.RemoteAddr().String(), verifiedFingerprint(s.Context()), text)
+}
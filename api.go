@@ -0,0 +1,227 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NaiKiDEV/ssh-chat/internal/model"
+	"github.com/charmbracelet/log"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+	// Upper bound for JSON request bodies
+	maxRequestSize = 64 * 1024
+)
+
+type apiRoom struct {
+	Id       string `json:"id"`
+	Online   int    `json:"online"`
+	Messages int    `json:"messages"`
+}
+
+// Messages are addressed by their position in the room history
+type apiMessage struct {
+	Id int `json:"id"`
+	model.Message
+}
+
+type apiMessagePage struct {
+	Messages []apiMessage `json:"messages"`
+	// Cursor for the previous page, passed back as ?before=
+	Before *int `json:"before"`
+}
+
+type apiPostMessageRequest struct {
+	Text string `json:"text"`
+}
+
+type apiError struct {
+	Error string `json:"error"`
+}
+
+// JSON API over the same rooms as the SSH server:
+//
+//	GET  /api/rooms
+//	GET  /api/rooms/{room}/messages?limit=50&before=<id>
+//	POST /api/rooms/{room}/messages   (Authorization: Bearer <token>)
+//	GET  /api/rooms/{room}/events     (server-sent events)
+//	POST /hooks/{room}/{token}        (incoming webhooks)
+func newAPIHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/rooms", handleListRooms)
+	mux.HandleFunc("GET /api/rooms/{room}/messages", handleListMessages)
+	mux.HandleFunc("POST /api/rooms/{room}/messages", handlePostMessage)
+	mux.HandleFunc("GET /api/rooms/{room}/events", handleRoomEvents)
+	mux.HandleFunc("POST /hooks/{room}/{token}", handleIncomingWebhook)
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Debug("Could not write API response", "error", err)
+	}
+}
+
+func writeAPIError(w http.ResponseWriter, status int, err string) {
+	writeJSON(w, status, apiError{Error: err})
+}
+
+func handleListRooms(w http.ResponseWriter, r *http.Request) {
+	rooms := []apiRoom{}
+	for _, serverRoom := range serverState.Rooms() {
+		serverRoom.mutex.Lock()
+		rooms = append(rooms, apiRoom{
+			Id:       serverRoom.roomId,
+			Online:   len(serverRoom.activeUsers),
+			Messages: len(serverRoom.messages),
+		})
+		serverRoom.mutex.Unlock()
+	}
+
+	writeJSON(w, http.StatusOK, rooms)
+}
+
+func handleListMessages(w http.ResponseWriter, r *http.Request) {
+	serverRoom, err := findRoom(r.PathValue("room"))
+	if err != nil {
+		writeAPIError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	limit, err := queryInt(r, "limit", defaultPageSize)
+	if err != nil || limit < 1 {
+		writeAPIError(w, http.StatusBadRequest, "invalid limit")
+		return
+	}
+	limit = min(limit, maxPageSize)
+
+	messages := serverRoom.Messages()
+
+	before, err := queryInt(r, "before", len(messages))
+	if err != nil || before < 0 {
+		writeAPIError(w, http.StatusBadRequest, "invalid before")
+		return
+	}
+	end := min(before, len(messages))
+	start := max(end-limit, 0)
+
+	page := apiMessagePage{Messages: []apiMessage{}}
+	for id := start; id < end; id++ {
+		page.Messages = append(page.Messages, apiMessage{Id: id, Message: messages[id]})
+	}
+	if start > 0 {
+		page.Before = &start
+	}
+
+	writeJSON(w, http.StatusOK, page)
+}
+
+func handlePostMessage(w http.ResponseWriter, r *http.Request) {
+	userName, ok := authenticateAPIRequest(r)
+	if !ok {
+		writeAPIError(w, http.StatusUnauthorized, "invalid or missing token")
+		return
+	}
+
+	serverRoom, err := findRoom(r.PathValue("room"))
+	if err != nil {
+		writeAPIError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	var body apiPostMessageRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestSize)).Decode(&body); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	text := strings.TrimSpace(body.Text)
+	if text == "" {
+		writeAPIError(w, http.StatusBadRequest, "text is empty")
+		return
+	}
+
+	msg, err := postMessage(serverRoom, model.Message{
+		Username:  userName,
+		Text:      text,
+		Timestamp: time.Now(),
+	})
+	if err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, msg)
+}
+
+// Streams room events as server-sent events until the client goes away
+func handleRoomEvents(w http.ResponseWriter, r *http.Request) {
+	serverRoom, err := findRoom(r.PathValue("room"))
+	if err != nil {
+		writeAPIError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeAPIError(w, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+
+	events := serverRoom.Subscribe()
+	defer serverRoom.Unsubscribe(events)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				log.Error("Could not encode event", "error", err)
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Kind, data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func authenticateAPIRequest(r *http.Request) (string, bool) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return "", false
+	}
+	for apiToken, userName := range serverState.Config().APITokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(apiToken)) == 1 {
+			return userName, true
+		}
+	}
+	return "", false
+}
+
+func queryInt(r *http.Request, key string, fallback int) (int, error) {
+	value := r.URL.Query().Get(key)
+	if value == "" {
+		return fallback, nil
+	}
+	return strconv.Atoi(value)
+}
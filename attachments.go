@@ -0,0 +1,146 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/NaiKiDEV/ssh-chat/internal/attachment"
+	"github.com/NaiKiDEV/ssh-chat/internal/model"
+	"github.com/NaiKiDEV/ssh-chat/internal/transcript"
+	"github.com/charmbracelet/ssh"
+	"github.com/charmbracelet/wish/scp"
+)
+
+// Nil when attachments_path is empty, uploads are refused then
+var attachments *attachment.Store
+
+var errUploadTarget = errors.New("copy files into a room, e.g. rooms/public/")
+
+func attachmentLimits() attachment.Limits {
+	cfg := serverState.Config()
+	return attachment.Limits{
+		MaxSize:   cfg.MaxAttachmentSize,
+		RoomQuota: cfg.MaxRoomAttachmentsSize,
+		Types:     cfg.AttachmentTypes,
+	}
+}
+
+// Starts an upload to target, which is either a room directory
+// (rooms/public, rooms/public/files) with the file name given separately or
+// the full path of the file in it
+func startUpload(target string, name string) (*room, *attachment.Upload, error) {
+	if attachments == nil {
+		return nil, nil, errors.New("file sharing is disabled")
+	}
+	if serverState.shuttingDown.Load() {
+		return nil, nil, errors.New("server is shutting down")
+	}
+
+	roomId, ok := uploadRoomId(transcriptPath(target))
+	if !ok {
+		target, name = path.Dir(transcriptPath(target)), path.Base(target)
+		if roomId, ok = uploadRoomId(target); !ok {
+			return nil, nil, errUploadTarget
+		}
+	}
+	serverRoom, err := findRoom(roomId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	upload, err := attachments.Create(roomId, name, attachmentLimits())
+	if err != nil {
+		return nil, nil, err
+	}
+	return serverRoom, upload, nil
+}
+
+func uploadRoomId(dir string) (string, bool) {
+	parts := strings.Split(dir, "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] != transcript.RoomsDir {
+		return "", false
+	}
+	if len(parts) == 3 && parts[2] != transcript.FilesDir {
+		return "", false
+	}
+	return parts[1], true
+}
+
+// Stores the upload and posts it to the room as an attachment message
+func shareAttachment(userName string, serverRoom *room, upload *attachment.Upload) error {
+	file, err := upload.Commit()
+	if err != nil {
+		return err
+	}
+	storedName := file.Path
+	file.Path = path.Join(transcript.RoomsDir, serverRoom.roomId, transcript.FilesDir, storedName)
+
+	_, err = postMessage(serverRoom, model.Message{
+		Username:   userName,
+		Text:       "shared " + file.Name,
+		Timestamp:  time.Now(),
+		Attachment: &file,
+	})
+	if err != nil {
+		// Nobody gets to know about the file, keep nothing around
+		_ = attachments.Remove(serverRoom.roomId, storedName)
+		return err
+	}
+	return nil
+}
+
+// Receives `scp -O report.log chat-host:rooms/public/`
+type attachmentSCPHandler struct{}
+
+func (attachmentSCPHandler) Mkdir(ssh.Session, *scp.DirEntry) error {
+	return errors.New("directories can't be shared, copy files one by one")
+}
+
+func (attachmentSCPHandler) Write(s ssh.Session, entry *scp.FileEntry) (int64, error) {
+	if limits := attachmentLimits(); entry.Size > limits.MaxSize {
+		return 0, fmt.Errorf("%w, the limit is %d bytes", attachment.ErrTooLarge, limits.MaxSize)
+	}
+
+	serverRoom, upload, err := startUpload(path.Dir(entry.Filepath), entry.Name)
+	if err != nil {
+		return 0, err
+	}
+	written, err := io.Copy(upload, entry.Reader)
+	// The entry reader is limited to the announced size, a stream cut short
+	// just ends early
+	if err == nil && written != entry.Size {
+		err = fmt.Errorf("transfer ended after %d of %d bytes", written, entry.Size)
+	}
+	if err != nil {
+		upload.Abort()
+		return written, err
+	}
+	return written, shareAttachment(s.User(), serverRoom, upload)
+}
+
+// Upload through SFTP, shared once the client closes the file
+type sftpUpload struct {
+	*attachment.Upload
+	userName   string
+	serverRoom *room
+	// Set when the connection dropped with the file still open, the server
+	// closes it anyway
+	transferErr error
+}
+
+// TransferError implements sftp.TransferError
+func (u *sftpUpload) TransferError(err error) {
+	u.transferErr = err
+}
+
+func (u *sftpUpload) Close() error {
+	if u.transferErr != nil {
+		u.Abort()
+		return u.transferErr
+	}
+	return shareAttachment(u.userName, u.serverRoom, u.Upload)
+}
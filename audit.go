@@ -0,0 +1,136 @@
+package main
+
+import (
+	"github.com/NaiKiDEV/ssh-chat/internal/audit"
+	"github.com/NaiKiDEV/ssh-chat/internal/config"
+	"github.com/charmbracelet/log"
+	"github.com/charmbracelet/ssh"
+	"github.com/charmbracelet/wish"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// Nil unless -audit-log is set, logging to it is a no-op then
+var auditLog *audit.Logger
+
+// Extension of the connection permissions holding the fingerprint of the key
+// the client signed with
+const fingerprintExtension = "pubkey-fp"
+
+type authAuditedKey struct{}
+
+// Every client is let in. Keys only count once the client proved it holds
+// them: the permissions returned for a key are only kept by the connection
+// when its signature checks out, clients merely offering a key get nothing.
+// Clients without keys fall back to keyboard-interactive without being
+// prompted. The auth is audited once the connection opens its first session.
+func withAuditedAuth() []ssh.Option {
+	return []ssh.Option{
+		func(srv *ssh.Server) error {
+			srv.ServerConfigCallback = func(ssh.Context) *gossh.ServerConfig {
+				return &gossh.ServerConfig{
+					PublicKeyCallback: func(_ gossh.ConnMetadata, key gossh.PublicKey) (*gossh.Permissions, error) {
+						return &gossh.Permissions{
+							Extensions: map[string]string{fingerprintExtension: gossh.FingerprintSHA256(key)},
+						}, nil
+					},
+				}
+			}
+			srv.ChannelHandlers = map[string]ssh.ChannelHandler{
+				"session": func(srv *ssh.Server, conn *gossh.ServerConn, newChan gossh.NewChannel, ctx ssh.Context) {
+					auditAuth(ctx)
+					ssh.DefaultSessionHandler(srv, conn, newChan, ctx)
+				},
+			}
+			return nil
+		},
+		// Keys left in the context by offers are never read, see verifiedFingerprint
+		wish.WithKeyboardInteractiveAuth(func(ssh.Context, gossh.KeyboardInteractiveChallenge) bool {
+			return true
+		}),
+	}
+}
+
+// Fingerprint of the key the client authenticated with, empty for clients
+// that did not prove they hold one
+func verifiedFingerprint(ctx ssh.Context) string {
+	conn, ok := ctx.Value(ssh.ContextKeyConn).(*gossh.ServerConn)
+	if !ok || conn.Permissions == nil {
+		return ""
+	}
+	return conn.Permissions.Extensions[fingerprintExtension]
+}
+
+func auditAuth(ctx ssh.Context) {
+	ctx.Lock()
+	defer ctx.Unlock()
+	if ctx.Value(authAuditedKey{}) != nil {
+		return
+	}
+	ctx.SetValue(authAuditedKey{}, true)
+
+	event := audit.Event{
+		Type:       audit.TypeAuth,
+		Result:     audit.ResultAccepted,
+		SessionId:  ctx.SessionID(),
+		User:       ctx.User(),
+		RemoteAddr: ctx.RemoteAddr().String(),
+		Method:     "keyboard-interactive",
+	}
+	if fingerprint := verifiedFingerprint(ctx); fingerprint != "" {
+		event.Method = "publickey"
+		event.Fingerprint = fingerprint
+	}
+	auditLog.Log(event)
+}
+
+func auditRoomPresence(eventType string, sessionId string, userName string, remoteAddr string, roomId string) {
+	auditLog.Log(audit.Event{
+		Type:       eventType,
+		Result:     audit.ResultOk,
+		SessionId:  sessionId,
+		User:       userName,
+		RemoteAddr: remoteAddr,
+		Room:       roomId,
+	})
+}
+
+func createRoom(roomId string, source string) {
+	if roomId == "" || !serverState.AddRoom(roomId) {
+		return
+	}
+	auditLog.Log(audit.Event{
+		Type:    audit.TypeRoomCreate,
+		Result:  audit.ResultOk,
+		Room:    roomId,
+		Details: map[string]string{"source": source},
+	})
+}
+
+// Re-reads the config file on SIGHUP. API tokens, incoming webhooks, admins,
+// the MOTD and new rooms apply right away, webhooks and plugins need a
+// restart.
+func reloadConfig() {
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Error("Could not reload config", "error", err)
+		auditLog.Log(audit.Event{
+			Type:    audit.TypeConfigReload,
+			Result:  audit.ResultFailed,
+			Details: map[string]string{"path": *configPath, "error": err.Error()},
+		})
+		return
+	}
+
+	warnInvalidKeyBindings(cfg)
+	serverState.SetConfig(cfg)
+	for _, roomId := range cfg.Rooms {
+		createRoom(roomId, "reload")
+	}
+
+	log.Info("Config reloaded", "path", *configPath)
+	auditLog.Log(audit.Event{
+		Type:    audit.TypeConfigReload,
+		Result:  audit.ResultOk,
+		Details: map[string]string{"path": *configPath},
+	})
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"time"
+
+	"github.com/NaiKiDEV/ssh-chat/internal/model"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type roomEventMsg struct {
+	Event model.Event
+}
+
+type serverNoticeMsg struct {
+	Notice serverNotice
+}
+
+type shutdownTickMsg struct{}
+
+type chatInputPostedMsg struct {
+	Err error
+}
+
+func waitForServerNotice(notices chan serverNotice) tea.Cmd {
+	return func() tea.Msg {
+		notice, ok := <-notices
+		if !ok {
+			return nil
+		}
+		return serverNoticeMsg{Notice: notice}
+	}
+}
+
+// Drives the countdown shown while the server shuts down
+func shutdownTick() tea.Cmd {
+	return tea.Tick(time.Second, func(time.Time) tea.Msg {
+		return shutdownTickMsg{}
+	})
+}
+
+// Plugins may take seconds to filter a message or answer a command, so chat
+// input is posted outside of Update. Posts of a session still land in the
+// order they were sent.
+func postChatInputCmd(session *clientSession, serverRoom *room, msg model.Message) tea.Cmd {
+	previous, done := session.queuePost()
+	return func() tea.Msg {
+		defer close(done)
+		if previous != nil {
+			<-previous
+		}
+		return chatInputPostedMsg{Err: postChatInput(serverRoom, msg)}
+	}
+}
+
+// Blocks until the room publishes the next event, resubscribe after every
+// received roomEventMsg to keep listening
+func waitForRoomEvent(events chan model.Event) tea.Cmd {
+	if events == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		event, ok := <-events
+		if !ok {
+			return nil
+		}
+		return roomEventMsg{Event: event}
+	}
+}
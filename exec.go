@@ -0,0 +1,189 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/NaiKiDEV/ssh-chat/internal/model"
+	"github.com/charmbracelet/ssh"
+	"github.com/charmbracelet/wish"
+)
+
+// Upper bound for message text read from stdin by `post`
+const maxPostSize = 64 * 1024
+
+var errUsage = errors.New("invalid usage")
+
+type execCommand struct {
+	usage       string
+	description string
+	run         func(s ssh.Session, args []string) error
+}
+
+// Commands available as `ssh chat-host <command> [args...]`. Sessions are
+// authenticated by the server exactly like interactive ones, the user name is
+// the SSH user.
+var execCommands = map[string]execCommand{
+	"rooms": {
+		usage:       "rooms",
+		description: "list rooms with their online and message counts",
+		run:         runRoomsCommand,
+	},
+	"who": {
+		usage:       "who <room>",
+		description: "list users online in a room",
+		run:         runWhoCommand,
+	},
+	"post": {
+		usage:       "post <room> [message...]",
+		description: "post a message to a room, reads stdin when no message is given",
+		run:         runPostCommand,
+	},
+	"announce": {
+		usage:       "announce [message...]",
+		description: "show a banner to every connected session, admins only, reads stdin when no message is given",
+		run:         runAnnounceCommand,
+	},
+	"tail": {
+		usage:       "tail [-n 20] [-format plain|json] [-follow=true] <room>",
+		description: "print recent history of a room, then stream new events",
+		run:         runTailCommand,
+	},
+}
+
+// Dispatches sessions started with a command, e.g. `ssh chat-host rooms`,
+// interactive sessions fall through to the next handler
+func execMiddleware() wish.Middleware {
+	return func(next ssh.Handler) ssh.Handler {
+		return func(s ssh.Session) {
+			args := s.Command()
+			if len(args) == 0 {
+				next(s)
+				return
+			}
+			_ = s.Exit(runExecCommand(s, args))
+		}
+	}
+}
+
+func runExecCommand(s ssh.Session, args []string) int {
+	name := args[0]
+	if name == "help" {
+		printExecHelp(s)
+		return 0
+	}
+
+	command, ok := execCommands[name]
+	if !ok {
+		wish.Errorf(s, "unknown command %q, run `help` to list commands\n", name)
+		return 1
+	}
+
+	if err := command.run(s, args[1:]); err != nil {
+		if errors.Is(err, errUsage) {
+			wish.Errorf(s, "usage: %s\n", command.usage)
+		} else {
+			wish.Errorf(s, "%s: %s\n", name, err)
+		}
+		return 1
+	}
+	return 0
+}
+
+func printExecHelp(s ssh.Session) {
+	names := make([]string, 0, len(execCommands))
+	for name := range execCommands {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	wish.Println(s, "Commands:")
+	for _, name := range names {
+		command := execCommands[name]
+		wish.Printf(s, "  %-58s %s\n", command.usage, command.description)
+	}
+}
+
+func findRoom(roomId string) (*room, error) {
+	serverRoom := serverState.Room(roomId)
+	if serverRoom == nil {
+		return nil, fmt.Errorf("room %q not found", roomId)
+	}
+	return serverRoom, nil
+}
+
+func runRoomsCommand(s ssh.Session, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+
+	for _, serverRoom := range serverState.Rooms() {
+		serverRoom.mutex.Lock()
+		online, messages := len(serverRoom.activeUsers), len(serverRoom.messages)
+		serverRoom.mutex.Unlock()
+		wish.Printf(s, "%s\t%d online\t%d messages\n", serverRoom.roomId, online, messages)
+	}
+	return nil
+}
+
+func runWhoCommand(s ssh.Session, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	serverRoom, err := findRoom(args[0])
+	if err != nil {
+		return err
+	}
+
+	activeUsers := serverRoom.ActiveUsers()
+
+	slices.Sort(activeUsers)
+	for _, userName := range activeUsers {
+		wish.Println(s, userName)
+	}
+	return nil
+}
+
+func runPostCommand(s ssh.Session, args []string) error {
+	if len(args) < 1 {
+		return errUsage
+	}
+	serverRoom, err := findRoom(args[0])
+	if err != nil {
+		return err
+	}
+
+	text, err := readMessageArgs(s, args[1:])
+	if err != nil {
+		return err
+	}
+
+	_, err = postMessage(serverRoom, model.Message{
+		Username:  s.User(),
+		Text:      text,
+		Timestamp: time.Now(),
+	})
+	return err
+}
+
+// Joins args into the message text, stdin is read instead when there are none
+func readMessageArgs(s ssh.Session, args []string) (string, error) {
+	text := strings.Join(args, " ")
+	if len(args) == 0 {
+		input, err := io.ReadAll(io.LimitReader(s, maxPostSize))
+		if err != nil {
+			return "", fmt.Errorf("could not read message: %w", err)
+		}
+		text = string(input)
+	}
+
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return "", errors.New("message is empty")
+	}
+	return text, nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/NaiKiDEV/ssh-chat/internal/config"
+	"github.com/NaiKiDEV/ssh-chat/internal/model"
+)
+
+// Longest bot name accepted in incoming payloads
+const maxBotNameLength = 32
+
+type incomingWebhookRequest struct {
+	Text string `json:"text"`
+	// Optional author shown instead of the integration name
+	Username string `json:"username"`
+}
+
+// Lets CI and monitoring systems announce into a room:
+//
+//	POST /hooks/{room}/{token}  {"text": "deploy done", "username": "deploy-bot"}
+func handleIncomingWebhook(w http.ResponseWriter, r *http.Request) {
+	roomId := r.PathValue("room")
+	hook, ok := findIncomingWebhook(roomId, r.PathValue("token"))
+	if !ok {
+		// Same answer for unknown rooms and tokens so neither can be probed
+		writeAPIError(w, http.StatusNotFound, "unknown webhook")
+		return
+	}
+
+	serverRoom, err := findRoom(roomId)
+	if err != nil {
+		writeAPIError(w, http.StatusNotFound, "unknown webhook")
+		return
+	}
+
+	var body incomingWebhookRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestSize)).Decode(&body); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	text := strings.TrimSpace(body.Text)
+	if text == "" {
+		writeAPIError(w, http.StatusBadRequest, "text is empty")
+		return
+	}
+
+	userName := strings.TrimSpace(body.Username)
+	if userName == "" {
+		userName = hook.Name
+	}
+	if runes := []rune(userName); len(runes) > maxBotNameLength {
+		userName = string(runes[:maxBotNameLength])
+	}
+
+	msg, err := postMessage(serverRoom, model.Message{
+		Username:    userName,
+		Text:        text,
+		Timestamp:   time.Now(),
+		Integration: hook.Name,
+	})
+	if err != nil {
+		writeAPIError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, msg)
+}
+
+func findIncomingWebhook(roomId string, token string) (config.IncomingWebhook, bool) {
+	for _, hook := range serverState.Config().IncomingWebhooks[roomId] {
+		if hook.Token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(hook.Token)) == 1 {
+			return hook, true
+		}
+	}
+	return config.IncomingWebhook{}, false
+}
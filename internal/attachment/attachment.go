@@ -0,0 +1,294 @@
+package attachment
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/NaiKiDEV/ssh-chat/internal/model"
+)
+
+// Longest file name accepted, in runes
+const maxNameLength = 128
+
+var (
+	ErrTooLarge      = errors.New("file is too large")
+	ErrInvalidName   = errors.New("invalid file name")
+	ErrQuotaExceeded = errors.New("the room has no space left for files")
+)
+
+type Limits struct {
+	// Largest accepted file in bytes
+	MaxSize int64
+	// Bytes the files of a room may take together, unlimited when 0
+	RoomQuota int64
+	// Accepted content types as sniffed from the file, entries ending with a
+	// slash match a whole family, e.g. "image/"
+	Types []string
+}
+
+// Store keeps attachments on disk in a directory per room, they outlive the
+// in-memory history
+type Store struct {
+	root string
+}
+
+func NewStore(root string) (*Store, error) {
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, fmt.Errorf("could not create attachments directory: %w", err)
+	}
+	return &Store{root: root}, nil
+}
+
+// FS serves the attachments of a room
+func (s *Store) FS(roomId string) fs.FS {
+	return os.DirFS(filepath.Join(s.root, roomId))
+}
+
+// Remove deletes a committed file, fileName is the Path Commit returned
+func (s *Store) Remove(roomId string, fileName string) error {
+	if !isValidName(roomId) || !isValidName(fileName) {
+		return ErrInvalidName
+	}
+	return os.Remove(filepath.Join(s.root, roomId, fileName))
+}
+
+// Create starts an upload of name into the room, it is only visible once
+// committed
+func (s *Store) Create(roomId string, name string, limits Limits) (*Upload, error) {
+	name = path.Base(name)
+	if !isValidName(name) {
+		return nil, ErrInvalidName
+	}
+	if !isValidName(roomId) {
+		return nil, fmt.Errorf("invalid room id %q", roomId)
+	}
+
+	dir := filepath.Join(s.root, roomId)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("could not create room directory: %w", err)
+	}
+	used, err := usage(dir)
+	if err != nil {
+		return nil, err
+	}
+	if limits.RoomQuota > 0 && used >= limits.RoomQuota {
+		return nil, quotaError(limits)
+	}
+	// Dot files are hidden from room listings, half written uploads never show
+	file, err := os.CreateTemp(dir, ".upload-*")
+	if err != nil {
+		return nil, fmt.Errorf("could not create upload: %w", err)
+	}
+	return &Upload{
+		file:   file,
+		dir:    dir,
+		roomId: roomId,
+		name:   name,
+		limits: limits,
+		used:   used,
+	}, nil
+}
+
+// Bytes taken by the files of a room directory, uploads in progress included
+func usage(dir string) (int64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, fmt.Errorf("could not list room directory: %w", err)
+	}
+	var used int64
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			// Uploads finishing or aborted meanwhile
+			continue
+		}
+		used += info.Size()
+	}
+	return used, nil
+}
+
+func quotaError(limits Limits) error {
+	return fmt.Errorf("%w, the limit is %d bytes", ErrQuotaExceeded, limits.RoomQuota)
+}
+
+func isValidName(name string) bool {
+	if name == "" || name == "." || name == ".." || strings.HasPrefix(name, ".") {
+		return false
+	}
+	if !utf8.ValidString(name) || utf8.RuneCountInString(name) > maxNameLength {
+		return false
+	}
+	return !strings.ContainsFunc(name, func(r rune) bool {
+		return unicode.IsControl(r) || r == '/' || r == '\\'
+	})
+}
+
+// Upload is written either sequentially (scp) or at offsets (sftp)
+type Upload struct {
+	mutex sync.Mutex
+
+	file   *os.File
+	dir    string
+	roomId string
+	name   string
+	limits Limits
+	// Bytes the room took when the upload started
+	used int64
+	size int64
+	done bool
+	// First failed write, an upload with gaps is never committed
+	err error
+}
+
+func (u *Upload) Write(p []byte) (int, error) {
+	u.mutex.Lock()
+	offset := u.size
+	u.mutex.Unlock()
+	return u.WriteAt(p, offset)
+}
+
+func (u *Upload) WriteAt(p []byte, offset int64) (int, error) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	if u.err != nil {
+		return 0, u.err
+	}
+	if offset+int64(len(p)) > u.limits.MaxSize {
+		u.err = fmt.Errorf("%w, the limit is %d bytes", ErrTooLarge, u.limits.MaxSize)
+		return 0, u.err
+	}
+	if u.limits.RoomQuota > 0 && u.used+offset+int64(len(p)) > u.limits.RoomQuota {
+		u.err = quotaError(u.limits)
+		return 0, u.err
+	}
+	n, err := u.file.WriteAt(p, offset)
+	if err != nil {
+		u.err = fmt.Errorf("could not write upload: %w", err)
+		return n, u.err
+	}
+	u.size = max(u.size, offset+int64(n))
+	return n, nil
+}
+
+// Commit checks the content type and publishes the file under a unique name,
+// the Path of the returned attachment is relative to the FS of the room
+func (u *Upload) Commit() (model.Attachment, error) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	if u.done {
+		return model.Attachment{}, errors.New("upload already finished")
+	}
+	u.done = true
+	if u.err != nil {
+		u.discard()
+		return model.Attachment{}, u.err
+	}
+
+	head := make([]byte, 512)
+	n, err := u.file.ReadAt(head, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		u.discard()
+		return model.Attachment{}, fmt.Errorf("could not read upload: %w", err)
+	}
+	contentType := detectContentType(u.name, head[:n])
+	if !isAllowedType(contentType, u.limits.Types) {
+		u.discard()
+		return model.Attachment{}, fmt.Errorf("files of type %s are not accepted", contentType)
+	}
+	// Uploads running side by side each fit on their own, not necessarily
+	// together. This one is counted too, it is still in the directory.
+	if u.limits.RoomQuota > 0 {
+		used, err := usage(u.dir)
+		if err != nil {
+			u.discard()
+			return model.Attachment{}, err
+		}
+		if used > u.limits.RoomQuota {
+			u.discard()
+			return model.Attachment{}, quotaError(u.limits)
+		}
+	}
+
+	// Temporary files are private, stored ones readable like any other
+	if err := u.file.Chmod(0o644); err != nil {
+		u.discard()
+		return model.Attachment{}, fmt.Errorf("could not store upload: %w", err)
+	}
+	if err := u.file.Close(); err != nil {
+		_ = os.Remove(u.file.Name())
+		return model.Attachment{}, fmt.Errorf("could not store upload: %w", err)
+	}
+	fileName := newId() + "-" + u.name
+	if err := os.Rename(u.file.Name(), filepath.Join(u.dir, fileName)); err != nil {
+		_ = os.Remove(u.file.Name())
+		return model.Attachment{}, fmt.Errorf("could not store upload: %w", err)
+	}
+
+	return model.Attachment{
+		Name:        u.name,
+		Size:        u.size,
+		ContentType: contentType,
+		Path:        fileName,
+	}, nil
+}
+
+// Abort throws away an upload that was not committed
+func (u *Upload) Abort() {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	if !u.done {
+		u.done = true
+		u.discard()
+	}
+}
+
+func (u *Upload) discard() {
+	_ = u.file.Close()
+	_ = os.Remove(u.file.Name())
+}
+
+// Sniffing catches binaries posing as text, the extension only refines what
+// looks like text, e.g. into text/markdown
+func detectContentType(name string, head []byte) string {
+	contentType, _, _ := mime.ParseMediaType(http.DetectContentType(head))
+	if contentType == "text/plain" {
+		if byExtension, _, err := mime.ParseMediaType(mime.TypeByExtension(path.Ext(name))); err == nil && strings.HasPrefix(byExtension, "text/") {
+			return byExtension
+		}
+	}
+	return contentType
+}
+
+func isAllowedType(contentType string, allowed []string) bool {
+	for _, pattern := range allowed {
+		if strings.HasSuffix(pattern, "/") && strings.HasPrefix(contentType, pattern) {
+			return true
+		}
+		if pattern == contentType {
+			return true
+		}
+	}
+	return false
+}
+
+func newId() string {
+	id := make([]byte, 4)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
@@ -0,0 +1,166 @@
+// Package audit writes security relevant events as JSON lines to a size
+// rotated file, so incidents can be reconstructed per session.
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+// Event types
+const (
+	TypeAuth         = "auth"
+	TypeRoomJoin     = "room.join"
+	TypeRoomLeave    = "room.leave"
+	TypeRoomCreate   = "room.create"
+	TypeModeration   = "moderation"
+	TypeConfigReload = "config.reload"
+)
+
+// Results
+const (
+	ResultAccepted = "accepted"
+	ResultRejected = "rejected"
+	ResultOk       = "ok"
+	ResultFailed   = "failed"
+)
+
+type Event struct {
+	Time        time.Time         `json:"time"`
+	Type        string            `json:"type"`
+	Result      string            `json:"result,omitempty"`
+	SessionId   string            `json:"session_id,omitempty"`
+	User        string            `json:"user,omitempty"`
+	RemoteAddr  string            `json:"remote_addr,omitempty"`
+	Method      string            `json:"method,omitempty"`
+	Fingerprint string            `json:"fingerprint,omitempty"`
+	Room        string            `json:"room,omitempty"`
+	Action      string            `json:"action,omitempty"`
+	Details     map[string]string `json:"details,omitempty"`
+}
+
+type Options struct {
+	Path string
+	// Size after which the file is rotated to Path.1, Path.1 to Path.2 etc.
+	MaxSize    int64
+	MaxBackups int
+}
+
+// Logger is safe for concurrent use, a nil Logger discards everything
+type Logger struct {
+	options Options
+
+	mutex sync.Mutex
+	file  *os.File
+	size  int64
+}
+
+func Open(options Options) (*Logger, error) {
+	l := &Logger{options: options}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *Logger) open() error {
+	file, err := os.OpenFile(l.options.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("could not open audit log: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("could not stat audit log: %w", err)
+	}
+	l.file = file
+	l.size = info.Size()
+	return nil
+}
+
+func (l *Logger) Log(event Event) {
+	if l == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	line, err := json.Marshal(event)
+	if err != nil {
+		log.Error("Could not encode audit event", "error", err)
+		return
+	}
+	line = append(line, '\n')
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.file == nil {
+		return
+	}
+	if l.options.MaxSize > 0 && l.size+int64(len(line)) > l.options.MaxSize && l.size > 0 {
+		if err := l.rotate(); err != nil {
+			log.Error("Could not rotate audit log", "error", err)
+		}
+	}
+
+	n, err := l.file.Write(line)
+	l.size += int64(n)
+	if err != nil {
+		log.Error("Could not write audit log", "error", err)
+	}
+}
+
+// Called with the mutex held
+func (l *Logger) rotate() error {
+	if err := l.file.Close(); err != nil {
+		return err
+	}
+
+	path := l.options.Path
+	for i := l.options.MaxBackups - 1; i >= 1; i-- {
+		_ = os.Rename(fmt.Sprintf("%s.%d", path, i), fmt.Sprintf("%s.%d", path, i+1))
+	}
+	if l.options.MaxBackups > 0 {
+		if err := os.Rename(path, path+".1"); err != nil {
+			return err
+		}
+	} else if err := os.Remove(path); err != nil {
+		return err
+	}
+
+	return l.open()
+}
+
+// Sync flushes the file to disk
+func (l *Logger) Sync() error {
+	if l == nil {
+		return nil
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	return l.file.Sync()
+}
+
+func (l *Logger) Close() error {
+	if l == nil {
+		return nil
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
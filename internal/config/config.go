@@ -0,0 +1,107 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Config holds the operator settings loaded from the JSON file passed with
+// -config. Every field is optional.
+type Config struct {
+	// Rooms created in addition to the built-in public and secret rooms, new
+	// entries are picked up on reload
+	Rooms []string `json:"rooms"`
+
+	// Bearer tokens accepted by the HTTP API, mapped to the user name messages
+	// are posted as
+	APITokens map[string]string `json:"api_tokens"`
+
+	// Outgoing webhooks keyed by room id
+	Webhooks map[string][]Webhook `json:"webhooks"`
+	// JSON lines file receiving webhook deliveries that failed for good
+	WebhookDeadLetterPath string `json:"webhook_dead_letter_path"`
+
+	// Incoming webhooks keyed by room id, served by the HTTP listener at
+	// POST /hooks/{room}/{token}
+	IncomingWebhooks map[string][]IncomingWebhook `json:"incoming_webhooks"`
+
+	// Out-of-process plugins, see the plugin package for the protocol
+	Plugins []Plugin `json:"plugins"`
+
+	// Message of the day shown on the login dialog and when joining a room
+	MOTD string `json:"motd"`
+	// SHA256 fingerprints of the public keys allowed to run admin commands
+	// such as announce, e.g. "SHA256:uNiVztksCsDhcc0u9e8BujQXVUpKZIDTMczCvj3tD2s"
+	Admins []string `json:"admins"`
+
+	// Directory files shared over scp are stored in, one subdirectory per
+	// room. File sharing is off unless it is set.
+	AttachmentsPath string `json:"attachments_path"`
+	// Largest accepted attachment in bytes
+	MaxAttachmentSize int64 `json:"max_attachment_size"`
+	// Bytes the attachments of a room may take together, unlimited when 0
+	MaxRoomAttachmentsSize int64 `json:"max_room_attachments_size"`
+	// Accepted attachment content types, entries ending with a slash match a
+	// whole family, e.g. "image/"
+	AttachmentTypes []string `json:"attachment_types"`
+
+	// Rows the message input grows to before it scrolls, for new sessions
+	ComposerHeight int `json:"composer_height"`
+	// Keys of named bindings replacing the defaults, e.g. {"chat.send":
+	// ["ctrl+s"]}, see the keymap package for the names. An empty list
+	// disables a binding.
+	KeyBindings map[string][]string `json:"key_bindings"`
+}
+
+type Plugin struct {
+	Name string `json:"name"`
+	// Executable and arguments of the plugin subprocess
+	Command []string `json:"command"`
+}
+
+type IncomingWebhook struct {
+	// Integration name shown next to posted messages
+	Name  string `json:"name"`
+	Token string `json:"token"`
+}
+
+type Webhook struct {
+	URL string `json:"url"`
+	// Key the payload signature is computed with
+	Secret string `json:"secret"`
+	// Event kinds to deliver (message, join, leave), all of them when empty
+	Events []string `json:"events"`
+}
+
+func Default() *Config {
+	return &Config{
+		APITokens: map[string]string{},
+		Webhooks:  map[string][]Webhook{},
+
+		IncomingWebhooks: map[string][]IncomingWebhook{},
+
+		MaxAttachmentSize:      10 * 1024 * 1024,
+		MaxRoomAttachmentsSize: 200 * 1024 * 1024,
+		AttachmentTypes:        []string{"text/", "image/", "application/pdf", "application/json", "application/zip", "application/x-gzip"},
+
+		ComposerHeight: 6,
+	}
+}
+
+// Load reads the config file at path, an empty path yields the defaults
+func Load(path string) (*Config, error) {
+	cfg := Default()
+	if path == "" {
+		return cfg, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read config: %w", err)
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("could not parse config %s: %w", path, err)
+	}
+	return cfg, nil
+}
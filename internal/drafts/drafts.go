@@ -0,0 +1,83 @@
+package drafts
+
+import (
+	"slices"
+	"sync"
+)
+
+// Sent messages remembered per user
+const maxHistory = 100
+
+// Store keeps what users typed without sending it, per room, and what they
+// sent, in memory. Both survive leaving a room and reconnecting until the
+// identity, such as a verified key fingerprint, is forgotten.
+type Store struct {
+	mutex sync.Mutex
+	users map[string]*userInput
+}
+
+type userInput struct {
+	drafts  map[string]string
+	history []string
+}
+
+func NewStore() *Store {
+	return &Store{users: map[string]*userInput{}}
+}
+
+func (s *Store) user(identity string) *userInput {
+	input, ok := s.users[identity]
+	if !ok {
+		input = &userInput{drafts: map[string]string{}}
+		s.users[identity] = input
+	}
+	return input
+}
+
+func (s *Store) Draft(identity string, roomId string) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.user(identity).drafts[roomId]
+}
+
+// SetDraft replaces the draft of a room, an empty one removes it
+func (s *Store) SetDraft(identity string, roomId string, text string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	input := s.user(identity)
+	if text == "" {
+		delete(input.drafts, roomId)
+		return
+	}
+	input.drafts[roomId] = text
+}
+
+// History returns the messages sent by a user, oldest first
+func (s *Store) History(identity string) []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return slices.Clone(s.user(identity).history)
+}
+
+// AddHistory remembers a sent message, repeating the last one adds nothing
+func (s *Store) AddHistory(identity string, text string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	input := s.user(identity)
+	if len(input.history) > 0 && input.history[len(input.history)-1] == text {
+		return
+	}
+	input.history = append(input.history, text)
+	if len(input.history) > maxHistory {
+		input.history = slices.Clone(input.history[len(input.history)-maxHistory:])
+	}
+}
+
+// Forget drops the drafts and history of an identity
+func (s *Store) Forget(identity string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.users, identity)
+}
@@ -0,0 +1,151 @@
+package emoji
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Shortcodes as used by GitHub and Slack. Only emoji presented as emoji by
+// default are listed, the ones needing a variation selector (e.g. ❤️) are one
+// or two cells wide depending on the terminal and would break layouts.
+var shortcodes = map[string]string{
+	"grinning": "😀", "smiley": "😃", "smile": "😄", "grin": "😁", "laughing": "😆",
+	"sweat_smile": "😅", "rofl": "🤣", "joy": "😂", "slightly_smiling_face": "🙂",
+	"upside_down_face": "🙃", "wink": "😉", "blush": "😊", "innocent": "😇",
+	"heart_eyes": "😍", "star_struck": "🤩", "kissing_heart": "😘", "kissing": "😗",
+	"yum": "😋", "stuck_out_tongue": "😛", "zany_face": "🤪", "money_mouth_face": "🤑",
+	"hugs": "🤗", "shushing_face": "🤫", "thinking": "🤔", "zipper_mouth_face": "🤐",
+	"raised_eyebrow": "🤨", "neutral_face": "😐", "expressionless": "😑",
+	"smirk": "😏", "unamused": "😒", "roll_eyes": "🙄", "grimacing": "😬",
+	"lying_face": "🤥", "relieved": "😌", "pensive": "😔", "sleepy": "😪",
+	"sleeping": "😴", "mask": "😷", "face_with_thermometer": "🤒",
+	"nauseated_face": "🤢", "vomiting_face": "🤮", "sneezing_face": "🤧",
+	"hot_face": "🥵", "cold_face": "🥶", "woozy_face": "🥴", "exploding_head": "🤯",
+	"cowboy_hat_face": "🤠", "partying_face": "🥳", "sunglasses": "😎",
+	"nerd_face": "🤓", "face_with_monocle": "🧐", "confused": "😕", "worried": "😟",
+	"slightly_frowning_face": "🙁", "open_mouth": "😮", "hushed": "😯",
+	"astonished": "😲", "flushed": "😳", "pleading_face": "🥺", "fearful": "😨",
+	"cold_sweat": "😰", "cry": "😢", "sob": "😭", "scream": "😱", "disappointed": "😞",
+	"weary": "😩", "tired_face": "😫", "yawning_face": "🥱", "triumph": "😤",
+	"rage": "😡", "angry": "😠", "skull": "💀", "poop": "💩", "clown_face": "🤡",
+	"ghost": "👻", "alien": "👽", "robot": "🤖", "smile_cat": "😸", "joy_cat": "😹",
+	"heart_eyes_cat": "😻", "see_no_evil": "🙈", "hear_no_evil": "🙉",
+	"speak_no_evil": "🙊",
+
+	"kiss": "💋", "love_letter": "💌", "cupid": "💘", "gift_heart": "💝",
+	"sparkling_heart": "💖", "heartpulse": "💗", "heartbeat": "💓",
+	"revolving_hearts": "💞", "two_hearts": "💕", "broken_heart": "💔",
+	"orange_heart": "🧡", "yellow_heart": "💛", "green_heart": "💚",
+	"blue_heart": "💙", "purple_heart": "💜", "black_heart": "🖤", "white_heart": "🤍",
+	"100": "💯", "boom": "💥", "dizzy": "💫", "sweat_drops": "💦", "dash": "💨",
+	"speech_balloon": "💬", "thought_balloon": "💭", "zzz": "💤",
+
+	"wave": "👋", "ok_hand": "👌", "crossed_fingers": "🤞", "point_left": "👈",
+	"point_right": "👉", "point_up_2": "👆", "point_down": "👇", "+1": "👍",
+	"thumbsup": "👍", "-1": "👎", "thumbsdown": "👎", "fist": "✊", "facepunch": "👊",
+	"clap": "👏", "raised_hands": "🙌", "handshake": "🤝", "pray": "🙏",
+	"muscle": "💪", "brain": "🧠", "eyes": "👀", "baby": "👶", "raising_hand": "🙋",
+	"ok_woman": "🙆", "no_good": "🙅", "facepalm": "🤦", "shrug": "🤷",
+	"santa": "🎅", "mage": "🧙", "zombie": "🧟", "ninja": "🥷", "dancer": "💃",
+	"running": "🏃",
+
+	"monkey_face": "🐵", "dog": "🐶", "wolf": "🐺", "fox_face": "🦊",
+	"cat": "🐱", "lion": "🦁", "tiger": "🐯", "cow": "🐮", "pig": "🐷", "hamster": "🐹",
+	"rabbit": "🐰", "bear": "🐻", "panda_face": "🐼", "chicken": "🐔",
+	"hatched_chick": "🐥", "penguin": "🐧", "eagle": "🦅", "duck": "🦆", "owl": "🦉",
+	"parrot": "🦜", "frog": "🐸", "turtle": "🐢", "snake": "🐍", "dragon": "🐉",
+	"sauropod": "🦕", "t-rex": "🦖", "whale": "🐳", "octopus": "🐙", "crab": "🦀",
+	"bug": "🐛", "bee": "🐝", "unicorn": "🦄", "rose": "🌹", "sunflower": "🌻",
+	"seedling": "🌱", "evergreen_tree": "🌲", "cactus": "🌵", "four_leaf_clover": "🍀",
+
+	"apple": "🍎", "avocado": "🥑", "pizza": "🍕", "taco": "🌮", "popcorn": "🍿",
+	"cake": "🍰", "birthday": "🎂", "coffee": "☕", "tea": "🍵", "beer": "🍺",
+	"beers": "🍻", "wine_glass": "🍷",
+
+	"earth_africa": "🌍", "earth_americas": "🌎", "house": "🏠", "office": "🏢",
+	"car": "🚗", "bike": "🚲", "ship": "🚢", "rocket": "🚀", "rotating_light": "🚨",
+	"construction": "🚧", "stop_sign": "🛑", "hourglass": "⌛", "watch": "⌚",
+	"alarm_clock": "⏰", "new_moon": "🌑", "crescent_moon": "🌙", "sun_with_face": "🌞",
+	"star": "⭐", "star2": "🌟", "rainbow": "🌈", "umbrella": "☔", "zap": "⚡",
+	"fire": "🔥", "droplet": "💧", "ocean": "🌊",
+
+	"tada": "🎉", "confetti_ball": "🎊", "sparkles": "✨", "gift": "🎁",
+	"trophy": "🏆", "sports_medal": "🏅", "soccer": "⚽", "basketball": "🏀",
+	"dart": "🎯", "video_game": "🎮", "game_die": "🎲", "art": "🎨",
+	"musical_note": "🎵", "notes": "🎶", "headphones": "🎧", "checkered_flag": "🏁",
+
+	"crown": "👑", "tophat": "🎩", "eyeglasses": "👓", "gem": "💎", "ring": "💍",
+	"moneybag": "💰", "money_with_wings": "💸", "computer": "💻", "bulb": "💡",
+	"mag": "🔍", "lock": "🔒", "unlock": "🔓", "key": "🔑", "hammer": "🔨",
+	"wrench": "🔧", "link": "🔗", "package": "📦", "memo": "📝", "pushpin": "📌",
+	"calendar": "📅", "chart_with_upwards_trend": "📈",
+	"chart_with_downwards_trend": "📉", "bar_chart": "📊", "bell": "🔔",
+	"loudspeaker": "📢", "mega": "📣",
+
+	"white_check_mark": "✅", "x": "❌", "no_entry": "⛔", "question": "❓",
+	"exclamation": "❗", "heavy_plus_sign": "➕", "heavy_minus_sign": "➖",
+	"ok": "🆗", "new": "🆕", "cool": "🆒", "free": "🆓", "sos": "🆘",
+}
+
+var (
+	shortcodePattern = regexp.MustCompile(`:([a-z0-9_+-]+):`)
+	names            = sortedNames()
+)
+
+type Match struct {
+	Shortcode string
+	Emoji     string
+}
+
+// Expand replaces known :shortcode:s, text between backticks is code and left
+// alone
+func Expand(text string) string {
+	parts := strings.Split(text, "`")
+	for idx := 0; idx < len(parts); idx += 2 {
+		parts[idx] = shortcodePattern.ReplaceAllStringFunc(parts[idx], func(match string) string {
+			if emoji, ok := shortcodes[strings.Trim(match, ":")]; ok {
+				return emoji
+			}
+			return match
+		})
+	}
+	return strings.Join(parts, "`")
+}
+
+// Complete lists up to limit shortcodes starting with query, followed by the
+// ones containing it, shorter ones first
+func Complete(query string, limit int) []Match {
+	query = strings.ToLower(query)
+	prefixed := []Match{}
+	containing := []Match{}
+	for _, name := range names {
+		switch {
+		case strings.HasPrefix(name, query):
+			prefixed = append(prefixed, Match{Shortcode: name, Emoji: shortcodes[name]})
+		case strings.Contains(name, query):
+			containing = append(containing, Match{Shortcode: name, Emoji: shortcodes[name]})
+		}
+	}
+	matches := append(prefixed, containing...)
+	return matches[:min(len(matches), limit)]
+}
+
+// IsShortcodeRune tells whether r can be part of a shortcode
+func IsShortcodeRune(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' || r == '+' || r == '-'
+}
+
+func sortedNames() []string {
+	sorted := make([]string, 0, len(shortcodes))
+	for name := range shortcodes {
+		sorted = append(sorted, name)
+	}
+	slices.SortFunc(sorted, func(a, b string) int {
+		if len(a) != len(b) {
+			return len(a) - len(b)
+		}
+		return strings.Compare(a, b)
+	})
+	return sorted
+}
@@ -0,0 +1,97 @@
+package keymap
+
+import (
+	"strings"
+
+	"github.com/NaiKiDEV/ssh-chat/internal/styles"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Gap between the columns of the help
+const helpColumnGap = 4
+
+// Group is a titled column of the help
+type Group struct {
+	Title    string
+	Bindings []key.Binding
+}
+
+func (k *KeyMap) LoginHelp() []Group {
+	return []Group{
+		{Title: "General", Bindings: []key.Binding{k.Global.Quit, k.Global.Help, k.Global.CloseHelp}},
+		{Title: "Login", Bindings: []key.Binding{k.Login.NextField, k.Login.PreviousField, k.Login.Submit, k.Login.Toggle, k.Login.SwitchButton}},
+	}
+}
+
+func (k *KeyMap) ChatHelp() []Group {
+	return []Group{
+		{Title: "General", Bindings: []key.Binding{k.Global.Quit, k.Global.Help, k.Global.CloseHelp}},
+		{Title: "Message input", Bindings: []key.Binding{
+			k.Chat.Send, k.Chat.Newline, k.Chat.Compose, k.Chat.HistoryBack, k.Chat.HistoryNext,
+			k.Chat.NextFocus, k.Chat.PreviousFocus, k.Chat.Blur, k.Chat.Activate,
+		}},
+		{Title: "Messages", Bindings: []key.Binding{
+			k.Chat.ScrollUp, k.Chat.ScrollDown, k.Chat.PageUp, k.Chat.PageDown,
+			k.Chat.HalfPageUp, k.Chat.HalfPageDown, k.Chat.ScrollToTop, k.Chat.ScrollToBottom, k.Chat.JumpToNew, k.Chat.ToggleSidebar,
+		}},
+		{Title: "Completion", Bindings: []key.Binding{k.Completion.Next, k.Completion.Previous, k.Completion.Accept, k.Completion.Cancel}},
+		{Title: "Emoji suggestions", Bindings: []key.Binding{k.Emoji.Previous, k.Emoji.Next, k.Emoji.Accept, k.Emoji.Dismiss}},
+		{Title: "Search results", Bindings: []key.Binding{
+			k.Search.Up, k.Search.Down, k.Search.PageUp, k.Search.PageDown,
+			k.Search.First, k.Search.Last, k.Search.Select, k.Search.Close,
+		}},
+	}
+}
+
+// RenderHelp lays the groups out in as many columns as fit width, disabled
+// bindings are left out
+func RenderHelp(groups []Group, width int, s *styles.ClientStyles) string {
+	rows := []string{}
+	row := []string{}
+	rowWidth := 0
+	for _, group := range groups {
+		column := renderGroup(group, s)
+		columnWidth := lipgloss.Width(column)
+		if len(row) > 0 && rowWidth+helpColumnGap+columnWidth > width {
+			rows = append(rows, lipgloss.JoinHorizontal(lipgloss.Top, row...))
+			row, rowWidth = nil, 0
+		}
+		if len(row) > 0 {
+			row = append(row, strings.Repeat(" ", helpColumnGap))
+			rowWidth += helpColumnGap
+		}
+		row = append(row, column)
+		rowWidth += columnWidth
+	}
+	if len(row) > 0 {
+		rows = append(rows, lipgloss.JoinHorizontal(lipgloss.Top, row...))
+	}
+	return lipgloss.NewStyle().MaxWidth(width).Render(strings.Join(rows, "\n\n"))
+}
+
+func renderGroup(group Group, s *styles.ClientStyles) string {
+	keyWidth := 0
+	for _, binding := range group.Bindings {
+		keyWidth = max(keyWidth, lipgloss.Width(binding.Help().Key))
+	}
+
+	keyStyle := s.BoldRegularTxt.Foreground(s.PrimaryColor).Width(keyWidth + 2)
+	descStyle := s.RegularTxt.Foreground(s.GreyColor)
+	lines := []string{s.BoldRegularTxt.Render(group.Title)}
+	for _, binding := range group.Bindings {
+		if !binding.Enabled() {
+			continue
+		}
+		lines = append(lines, keyStyle.Render(binding.Help().Key)+descStyle.Render(binding.Help().Desc))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// FirstKey names the first key of a binding for hints, empty when disabled
+func FirstKey(binding key.Binding) string {
+	if !binding.Enabled() || len(binding.Keys()) == 0 {
+		return ""
+	}
+	return helpKeys(binding.Keys()[:1])
+}
@@ -0,0 +1,275 @@
+package keymap
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+)
+
+// KeyMap holds the key bindings of every view. Each binding has a name such as
+// chat.send which operators override in the config and users with the
+// SSH_CHAT_KEYS env var.
+type KeyMap struct {
+	Global     GlobalKeys
+	Login      LoginKeys
+	Chat       ChatKeys
+	Emoji      EmojiKeys
+	Completion CompletionKeys
+	Search     SearchKeys
+}
+
+type GlobalKeys struct {
+	Quit key.Binding
+	// Printable keys such as ? only open the help while no text is typed
+	Help      key.Binding
+	CloseHelp key.Binding
+}
+
+type LoginKeys struct {
+	NextField     key.Binding
+	PreviousField key.Binding
+	Submit        key.Binding
+	Toggle        key.Binding
+	SwitchButton  key.Binding
+}
+
+type ChatKeys struct {
+	Send          key.Binding
+	Newline       key.Binding
+	Compose       key.Binding
+	NextFocus     key.Binding
+	PreviousFocus key.Binding
+	Blur          key.Binding
+	// Focuses the input again or presses the focused button
+	Activate    key.Binding
+	HistoryBack key.Binding
+	HistoryNext key.Binding
+
+	// Scrolling the messages while the input is not focused
+	ScrollUp       key.Binding
+	ScrollDown     key.Binding
+	PageUp         key.Binding
+	PageDown       key.Binding
+	HalfPageUp     key.Binding
+	HalfPageDown   key.Binding
+	ScrollToTop    key.Binding
+	ScrollToBottom key.Binding
+	// Work wherever the focus is
+	JumpToNew     key.Binding
+	ToggleSidebar key.Binding
+}
+
+type EmojiKeys struct {
+	Previous key.Binding
+	Next     key.Binding
+	Accept   key.Binding
+	Dismiss  key.Binding
+}
+
+// Tab completion of @users, #rooms and /commands in the input
+type CompletionKeys struct {
+	Next     key.Binding
+	Previous key.Binding
+	Accept   key.Binding
+	Cancel   key.Binding
+}
+
+type SearchKeys struct {
+	Up       key.Binding
+	Down     key.Binding
+	PageUp   key.Binding
+	PageDown key.Binding
+	First    key.Binding
+	Last     key.Binding
+	Select   key.Binding
+	Close    key.Binding
+}
+
+func Default() *KeyMap {
+	return &KeyMap{
+		Global: GlobalKeys{
+			Quit:      newBinding("quit", "ctrl+c"),
+			Help:      newBinding("toggle this help", "?", "f1"),
+			CloseHelp: newBinding("close this help", "esc", "q"),
+		},
+		Login: LoginKeys{
+			NextField:     newBinding("next field", "tab"),
+			PreviousField: newBinding("previous field", "shift+tab"),
+			Submit:        newBinding("join / activate", "enter"),
+			Toggle:        newBinding("toggle option", "enter", " "),
+			SwitchButton:  newBinding("switch button", "left", "right", "h", "l"),
+		},
+		Chat: ChatKeys{
+			Send: newBinding("send message", "enter"),
+			// Terminals set up to tell shift+enter apart send it as alt+enter
+			Newline: newBinding("new line", "alt+enter", "ctrl+j"),
+			Compose: newBinding("full screen composer", "ctrl+o"),
+			// Tab completes in the input, it only moves the focus elsewhere
+			NextFocus:     newBinding("focus next", "f6", "tab"),
+			PreviousFocus: newBinding("focus previous", "shift+f6", "shift+tab"),
+			Blur:          newBinding("leave the input / close", "esc"),
+			Activate:      newBinding("focus input / press button", "enter"),
+			HistoryBack:   newBinding("previous sent message", "up"),
+			HistoryNext:   newBinding("next sent message", "down"),
+
+			ScrollUp:       newBinding("scroll up", "up", "k"),
+			ScrollDown:     newBinding("scroll down", "down", "j"),
+			PageUp:         newBinding("page up", "pgup", "b"),
+			PageDown:       newBinding("page down", "pgdown", "f", " "),
+			HalfPageUp:     newBinding("half page up", "u", "ctrl+u"),
+			HalfPageDown:   newBinding("half page down", "d", "ctrl+d"),
+			ScrollToTop:    newBinding("oldest message", "home", "g"),
+			ScrollToBottom: newBinding("newest message", "end", "G"),
+			JumpToNew:      newBinding("jump to new messages", "ctrl+g"),
+			ToggleSidebar:  newBinding("show / hide online users", "f2"),
+		},
+		Emoji: EmojiKeys{
+			Previous: newBinding("previous emoji", "up", "ctrl+p"),
+			Next:     newBinding("next emoji", "down", "ctrl+n"),
+			Accept:   newBinding("insert emoji", "tab", "enter"),
+			Dismiss:  newBinding("close suggestions", "esc"),
+		},
+		Completion: CompletionKeys{
+			Next:     newBinding("complete / next candidate", "tab"),
+			Previous: newBinding("previous candidate", "shift+tab"),
+			Accept:   newBinding("accept candidate", "enter"),
+			Cancel:   newBinding("undo completion", "esc"),
+		},
+		Search: SearchKeys{
+			Up:       newBinding("previous result", "up", "k"),
+			Down:     newBinding("next result", "down", "j"),
+			PageUp:   newBinding("page up", "pgup"),
+			PageDown: newBinding("page down", "pgdown"),
+			First:    newBinding("newest result", "home", "g"),
+			Last:     newBinding("oldest result", "end", "G"),
+			Select:   newBinding("jump to message", "enter"),
+			Close:    newBinding("close results", "esc", "q"),
+		},
+	}
+}
+
+func newBinding(description string, keys ...string) key.Binding {
+	return key.NewBinding(key.WithKeys(keys...), key.WithHelp(helpKeys(keys), description))
+}
+
+func helpKeys(keys []string) string {
+	names := make([]string, 0, len(keys))
+	for _, k := range keys {
+		if k == " " {
+			k = "space"
+		}
+		names = append(names, k)
+	}
+	return strings.Join(names, "/")
+}
+
+// Bindings returns every binding by name
+func (k *KeyMap) Bindings() map[string]*key.Binding {
+	return map[string]*key.Binding{
+		"global.quit":       &k.Global.Quit,
+		"global.help":       &k.Global.Help,
+		"global.close_help": &k.Global.CloseHelp,
+
+		"login.next_field":     &k.Login.NextField,
+		"login.previous_field": &k.Login.PreviousField,
+		"login.submit":         &k.Login.Submit,
+		"login.toggle":         &k.Login.Toggle,
+		"login.switch_button":  &k.Login.SwitchButton,
+
+		"chat.send":             &k.Chat.Send,
+		"chat.newline":          &k.Chat.Newline,
+		"chat.compose":          &k.Chat.Compose,
+		"chat.next_focus":       &k.Chat.NextFocus,
+		"chat.previous_focus":   &k.Chat.PreviousFocus,
+		"chat.blur":             &k.Chat.Blur,
+		"chat.activate":         &k.Chat.Activate,
+		"chat.history_back":     &k.Chat.HistoryBack,
+		"chat.history_next":     &k.Chat.HistoryNext,
+		"chat.scroll_up":        &k.Chat.ScrollUp,
+		"chat.scroll_down":      &k.Chat.ScrollDown,
+		"chat.page_up":          &k.Chat.PageUp,
+		"chat.page_down":        &k.Chat.PageDown,
+		"chat.half_page_up":     &k.Chat.HalfPageUp,
+		"chat.half_page_down":   &k.Chat.HalfPageDown,
+		"chat.scroll_to_top":    &k.Chat.ScrollToTop,
+		"chat.scroll_to_bottom": &k.Chat.ScrollToBottom,
+		"chat.jump_to_new":      &k.Chat.JumpToNew,
+		"chat.toggle_sidebar":   &k.Chat.ToggleSidebar,
+
+		"emoji.previous": &k.Emoji.Previous,
+		"emoji.next":     &k.Emoji.Next,
+		"emoji.accept":   &k.Emoji.Accept,
+		"emoji.dismiss":  &k.Emoji.Dismiss,
+
+		"completion.next":     &k.Completion.Next,
+		"completion.previous": &k.Completion.Previous,
+		"completion.accept":   &k.Completion.Accept,
+		"completion.cancel":   &k.Completion.Cancel,
+
+		"search.up":        &k.Search.Up,
+		"search.down":      &k.Search.Down,
+		"search.page_up":   &k.Search.PageUp,
+		"search.page_down": &k.Search.PageDown,
+		"search.first":     &k.Search.First,
+		"search.last":      &k.Search.Last,
+		"search.select":    &k.Search.Select,
+		"search.close":     &k.Search.Close,
+	}
+}
+
+// Override rebinds the named bindings, an empty key list disables one and
+// "space" stands for the space bar. Unknown names are reported after the known
+// ones are applied.
+func (k *KeyMap) Override(overrides map[string][]string) error {
+	bindings := k.Bindings()
+	unknown := []string{}
+	for name, keys := range overrides {
+		binding, ok := bindings[name]
+		if !ok {
+			unknown = append(unknown, name)
+			continue
+		}
+		// "space" reads better than " " in JSON and env vars
+		keys = slices.Clone(keys)
+		for idx, k := range keys {
+			if k == "space" {
+				keys[idx] = " "
+			}
+		}
+		binding.SetKeys(keys...)
+		binding.SetHelp(helpKeys(keys), binding.Help().Desc)
+		binding.SetEnabled(len(keys) > 0)
+	}
+	if len(unknown) > 0 {
+		slices.Sort(unknown)
+		return fmt.Errorf("unknown key bindings: %s", strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// ParseOverrides reads overrides written as
+// "chat.send=ctrl+s,enter;search.down=j,down"
+func ParseOverrides(spec string) (map[string][]string, error) {
+	overrides := map[string][]string{}
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(entry, "=")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, errors.New("key bindings are written as name=key,key;name=key")
+		}
+		keys := []string{}
+		for _, k := range strings.Split(value, ",") {
+			if k = strings.TrimSpace(k); k != "" {
+				keys = append(keys, k)
+			}
+		}
+		overrides[strings.TrimSpace(name)] = keys
+	}
+	return overrides, nil
+}
@@ -0,0 +1,245 @@
+// Package metrics is a small Prometheus text format exporter, enough for
+// counters, gauges and histograms without pulling in the client library.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+type collector interface {
+	write(w io.Writer)
+}
+
+type Registry struct {
+	mutex      sync.Mutex
+	collectors []collector
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) register(c collector) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.collectors = append(r.collectors, c)
+}
+
+// WriteText writes every metric in the Prometheus text exposition format
+func (r *Registry) WriteText(w io.Writer) {
+	r.mutex.Lock()
+	collectors := slices.Clone(r.collectors)
+	r.mutex.Unlock()
+
+	for _, c := range collectors {
+		c.write(w)
+	}
+}
+
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.WriteText(w)
+	})
+}
+
+type desc struct {
+	name       string
+	help       string
+	metricType string
+	labelNames []string
+}
+
+func (d desc) writeHeader(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", d.name, d.help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", d.name, d.metricType)
+}
+
+func (d desc) labels(labelValues []string, extra ...string) string {
+	pairs := make([]string, 0, len(labelValues)+len(extra)/2)
+	for i, value := range labelValues {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, d.labelNames[i], labelValueEscaper.Replace(value)))
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, extra[i], labelValueEscaper.Replace(extra[i+1])))
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// Label values are joined into a single map key
+func labelKey(labelValues []string) string {
+	return strings.Join(labelValues, "\x00")
+}
+
+func splitLabelKey(key string, count int) []string {
+	if count == 0 {
+		return nil
+	}
+	return strings.SplitN(key, "\x00", count)
+}
+
+func formatFloat(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// valueVec backs counters and gauges, one value per label combination
+type valueVec struct {
+	desc
+	mutex  sync.Mutex
+	values map[string]float64
+}
+
+func (v *valueVec) add(delta float64, labelValues []string) {
+	if len(labelValues) != len(v.labelNames) {
+		panic(fmt.Sprintf("metric %s expects %d label values", v.name, len(v.labelNames)))
+	}
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	v.values[labelKey(labelValues)] += delta
+}
+
+func (v *valueVec) write(w io.Writer) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	v.writeHeader(w)
+	keys := make([]string, 0, len(v.values))
+	for key := range v.values {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		fmt.Fprintf(w, "%s%s %s\n", v.name, v.labels(splitLabelKey(key, len(v.labelNames))), formatFloat(v.values[key]))
+	}
+}
+
+type Counter struct {
+	valueVec
+}
+
+func (r *Registry) NewCounter(name string, help string, labelNames ...string) *Counter {
+	c := &Counter{valueVec{
+		desc:   desc{name: name, help: help, metricType: "counter", labelNames: labelNames},
+		values: map[string]float64{},
+	}}
+	if len(labelNames) == 0 {
+		c.values[""] = 0
+	}
+	r.register(c)
+	return c
+}
+
+func (c *Counter) Inc(labelValues ...string) {
+	c.add(1, labelValues)
+}
+
+type Gauge struct {
+	valueVec
+}
+
+func (r *Registry) NewGauge(name string, help string, labelNames ...string) *Gauge {
+	g := &Gauge{valueVec{
+		desc:   desc{name: name, help: help, metricType: "gauge", labelNames: labelNames},
+		values: map[string]float64{},
+	}}
+	if len(labelNames) == 0 {
+		g.values[""] = 0
+	}
+	r.register(g)
+	return g
+}
+
+func (g *Gauge) Add(delta float64, labelValues ...string) {
+	g.add(delta, labelValues)
+}
+
+// GaugeFunc computes its values on every scrape, keyed by the single label
+type GaugeFunc struct {
+	desc
+	collect func() map[string]float64
+}
+
+func (r *Registry) NewGaugeFunc(name string, help string, labelName string, collect func() map[string]float64) *GaugeFunc {
+	g := &GaugeFunc{
+		desc:    desc{name: name, help: help, metricType: "gauge", labelNames: []string{labelName}},
+		collect: collect,
+	}
+	r.register(g)
+	return g
+}
+
+func (g *GaugeFunc) write(w io.Writer) {
+	values := g.collect()
+
+	g.writeHeader(w)
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		fmt.Fprintf(w, "%s%s %s\n", g.name, g.labels([]string{key}), formatFloat(values[key]))
+	}
+}
+
+type Histogram struct {
+	desc
+	buckets []float64
+
+	mutex  sync.Mutex
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// Buckets are upper bounds in ascending order, +Inf is implied
+func (r *Registry) NewHistogram(name string, help string, buckets []float64) *Histogram {
+	h := &Histogram{
+		desc:    desc{name: name, help: help, metricType: "histogram"},
+		buckets: buckets,
+		counts:  make([]uint64, len(buckets)),
+	}
+	r.register(h)
+	return h
+}
+
+func (h *Histogram) Observe(value float64) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	for i, bound := range h.buckets {
+		if value <= bound {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += value
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.writeHeader(w)
+	for i, bound := range h.buckets {
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labels(nil, "le", formatFloat(bound)), h.counts[i])
+	}
+	fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labels(nil, "le", "+Inf"), h.count)
+	fmt.Fprintf(w, "%s_sum %s\n", h.name, formatFloat(h.sum))
+	fmt.Fprintf(w, "%s_count %d\n", h.name, h.count)
+}
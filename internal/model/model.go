@@ -1,9 +1,79 @@
 package model
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
 type Message struct {
-	Username  string
-	Text      string
-	Timestamp time.Time
+	Username  string    `json:"username"`
+	Text      string    `json:"text"`
+	Timestamp time.Time `json:"timestamp"`
+	// Name of the integration that posted the message, empty for users
+	Integration string `json:"integration,omitempty"`
+	// File shared with the message
+	Attachment *Attachment `json:"attachment,omitempty"`
+}
+
+type Attachment struct {
+	Name        string `json:"name"`
+	Size        int64  `json:"size"`
+	ContentType string `json:"content_type"`
+	// Where scp and sftp clients download it from, e.g.
+	// rooms/public/files/3f2a9c1e-report.log
+	Path string `json:"path"`
+}
+
+// PlainText is Text followed by a line describing the attachment, for
+// outputs that can't show it otherwise
+func (m Message) PlainText() string {
+	if m.Attachment == nil {
+		return m.Text
+	}
+	return fmt.Sprintf("%s\nfile: %s at %s", m.Text, m.Attachment.Summary(), m.Attachment.Path)
+}
+
+// Summary is the name with a human readable size, e.g. "report.log (1.2 KB)"
+func (a Attachment) Summary() string {
+	const unit = 1024
+	if a.Size < unit {
+		return fmt.Sprintf("%s (%d B)", a.Name, a.Size)
+	}
+	size, prefix := float64(a.Size)/unit, 0
+	for size >= unit && prefix < 3 {
+		size /= unit
+		prefix++
+	}
+	return fmt.Sprintf("%s (%.1f %cB)", a.Name, size, "KMGT"[prefix])
+}
+
+// DisplayName labels integration messages so they can't pass for users in
+// plain text output
+func (m Message) DisplayName() string {
+	if m.Integration != "" {
+		return fmt.Sprintf("%s [%s]", m.Username, m.Integration)
+	}
+	return m.Username
+}
+
+// Room event kinds
+const (
+	EventMessage = "message"
+	EventJoin    = "join"
+	EventLeave   = "leave"
+	EventTopic   = "topic"
+	// Last event of a subscription that fell too far behind, its channel is
+	// closed right after
+	EventDropped = "dropped"
+)
+
+// Event is published by a room to its subscribers whenever something visible
+// happens in it. Message is only set for EventMessage, Topic for EventTopic.
+type Event struct {
+	Kind      string    `json:"type"`
+	RoomId    string    `json:"room"`
+	Username  string    `json:"username"`
+	Message   *Message  `json:"message,omitempty"`
+	Topic     string    `json:"topic,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
 }
@@ -0,0 +1,67 @@
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+)
+
+const (
+	maxDice  = 100
+	maxSides = 1000
+)
+
+var errInvalidDice = errors.New("dice have to look like 2d6, at most 100d1000")
+
+// DiceRoller is a built-in bot answering `/roll 2d6`
+type DiceRoller struct{}
+
+func (DiceRoller) Name() string {
+	return "dice"
+}
+
+func (DiceRoller) Commands() []Command {
+	return []Command{{
+		Name:        "roll",
+		Usage:       "/roll [NdM]",
+		Description: "roll dice, 1d6 by default",
+	}}
+}
+
+func (DiceRoller) HandleCommand(call CommandCall) ([]string, error) {
+	spec := "1d6"
+	if len(call.Args) > 0 {
+		spec = strings.ToLower(call.Args[0])
+	}
+
+	countText, sidesText, ok := strings.Cut(spec, "d")
+	if !ok {
+		return nil, errInvalidDice
+	}
+	count := 1
+	if countText != "" {
+		var err error
+		if count, err = strconv.Atoi(countText); err != nil {
+			return nil, errInvalidDice
+		}
+	}
+	sides, err := strconv.Atoi(sidesText)
+	if err != nil || count < 1 || count > maxDice || sides < 2 || sides > maxSides {
+		return nil, errInvalidDice
+	}
+
+	rolls := make([]string, count)
+	total := 0
+	for i := range rolls {
+		roll := rand.IntN(sides) + 1
+		total += roll
+		rolls[i] = strconv.Itoa(roll)
+	}
+
+	if count == 1 {
+		return []string{fmt.Sprintf("%s rolled %s: %d", call.Username, spec, total)}, nil
+	}
+	return []string{fmt.Sprintf("%s rolled %s: %d (%s)", call.Username, spec, total, strings.Join(rolls, " + "))}, nil
+}
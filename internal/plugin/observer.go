@@ -0,0 +1,76 @@
+package plugin
+
+import (
+	"sync"
+
+	"github.com/NaiKiDEV/ssh-chat/internal/model"
+)
+
+type notification struct {
+	roomId string
+	msg    model.Message
+}
+
+// observerQueue delivers messages to one observer from a single goroutine.
+// The queue is unbounded so a slow observer never holds up posting and
+// never loses messages.
+type observerQueue struct {
+	observer Observer
+
+	mutex   sync.Mutex
+	pending []notification
+	closed  bool
+	wake    chan struct{}
+}
+
+func newObserverQueue(observer Observer) *observerQueue {
+	queue := &observerQueue{
+		observer: observer,
+		wake:     make(chan struct{}, 1),
+	}
+	go queue.run()
+	return queue
+}
+
+func (q *observerQueue) push(n notification) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.closed {
+		return
+	}
+	q.pending = append(q.pending, n)
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Messages still pending are delivered before the goroutine exits
+func (q *observerQueue) close() {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if !q.closed {
+		q.closed = true
+		close(q.wake)
+	}
+}
+
+func (q *observerQueue) run() {
+	for range q.wake {
+		for {
+			q.mutex.Lock()
+			if len(q.pending) == 0 {
+				q.mutex.Unlock()
+				break
+			}
+			n := q.pending[0]
+			q.pending[0] = notification{}
+			q.pending = q.pending[1:]
+			q.mutex.Unlock()
+
+			q.observer.MessagePosted(n.roomId, n.msg)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+// Package plugin is the extension point of the message pipeline. Plugins are
+// registered on a Pipeline and implement any combination of Filter, Observer
+// and CommandHandler. Out-of-process plugins are wrapped by Process.
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/NaiKiDEV/ssh-chat/internal/model"
+	"github.com/charmbracelet/log"
+)
+
+var ErrUnknownCommand = errors.New("unknown command")
+
+type Plugin interface {
+	Name() string
+}
+
+// Host is handed to plugins implementing Initializer so they can post on
+// their own, e.g. for reminders. Messages are posted as the plugin.
+type Host interface {
+	PostMessage(pluginName string, roomId string, text string) error
+}
+
+type Initializer interface {
+	Init(host Host) error
+}
+
+// Filter runs before a message is stored, it may rewrite the message or
+// reject it by returning an error which is shown to the author.
+type Filter interface {
+	FilterMessage(roomId string, msg model.Message) (model.Message, error)
+}
+
+// Observer is notified after a message was stored. Integration messages,
+// including the ones posted by plugins, are delivered too.
+type Observer interface {
+	MessagePosted(roomId string, msg model.Message)
+}
+
+type Command struct {
+	Name        string `json:"name"`
+	Usage       string `json:"usage"`
+	Description string `json:"description"`
+}
+
+type CommandCall struct {
+	RoomId   string   `json:"room"`
+	Username string   `json:"username"`
+	Name     string   `json:"name"`
+	Args     []string `json:"args"`
+}
+
+// CommandHandler handles `/<name> args...` typed by users, the returned
+// replies are posted to the room as the plugin
+type CommandHandler interface {
+	Commands() []Command
+	HandleCommand(call CommandCall) ([]string, error)
+}
+
+type Pipeline struct {
+	mutex     sync.RWMutex
+	plugins   []Plugin
+	commands  map[string]CommandHandler
+	observers []*observerQueue
+	host      Host
+}
+
+func NewPipeline(host Host) *Pipeline {
+	return &Pipeline{
+		commands: map[string]CommandHandler{},
+		host:     host,
+	}
+}
+
+func (p *Pipeline) Register(plugin Plugin) error {
+	if initializer, ok := plugin.(Initializer); ok {
+		if err := initializer.Init(p.host); err != nil {
+			return fmt.Errorf("could not init plugin %s: %w", plugin.Name(), err)
+		}
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if handler, ok := plugin.(CommandHandler); ok {
+		for _, command := range handler.Commands() {
+			if _, taken := p.commands[command.Name]; taken {
+				log.Warn("Command already registered, skipping", "plugin", plugin.Name(), "command", command.Name)
+				continue
+			}
+			p.commands[command.Name] = handler
+		}
+	}
+	if observer, ok := plugin.(Observer); ok {
+		p.observers = append(p.observers, newObserverQueue(observer))
+	}
+	p.plugins = append(p.plugins, plugin)
+	return nil
+}
+
+// Filter runs the message through every registered filter in order
+func (p *Pipeline) Filter(roomId string, msg model.Message) (model.Message, error) {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	for _, plugin := range p.plugins {
+		filter, ok := plugin.(Filter)
+		if !ok {
+			continue
+		}
+		var err error
+		if msg, err = filter.FilterMessage(roomId, msg); err != nil {
+			return msg, err
+		}
+	}
+	return msg, nil
+}
+
+// Notify hands the stored message to every observer without blocking, each
+// observer gets messages one at a time in the order Notify was called
+func (p *Pipeline) Notify(roomId string, msg model.Message) {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	for _, queue := range p.observers {
+		queue.push(notification{roomId: roomId, msg: msg})
+	}
+}
+
+// ParseCommand splits `/name args...`, ok is false for regular messages
+func ParseCommand(text string) (name string, args []string, ok bool) {
+	if !strings.HasPrefix(text, "/") {
+		return "", nil, false
+	}
+	fields := strings.Fields(strings.TrimPrefix(text, "/"))
+	if len(fields) == 0 {
+		return "", nil, false
+	}
+	return fields[0], fields[1:], true
+}
+
+// RunCommand returns the name of the handling plugin with its replies
+func (p *Pipeline) RunCommand(call CommandCall) (string, []string, error) {
+	p.mutex.RLock()
+	handler, ok := p.commands[call.Name]
+	p.mutex.RUnlock()
+	if !ok {
+		return "", nil, fmt.Errorf("%w /%s", ErrUnknownCommand, call.Name)
+	}
+
+	replies, err := handler.HandleCommand(call)
+	return handler.(Plugin).Name(), replies, err
+}
+
+func (p *Pipeline) Commands() []Command {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	commands := []Command{}
+	for _, plugin := range p.plugins {
+		if handler, ok := plugin.(CommandHandler); ok {
+			commands = append(commands, handler.Commands()...)
+		}
+	}
+	slices.SortFunc(commands, func(a, b Command) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return commands
+}
+
+// Close stops plugins holding resources, e.g. subprocesses
+func (p *Pipeline) Close() {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	for _, queue := range p.observers {
+		queue.close()
+	}
+	for _, plugin := range p.plugins {
+		if closer, ok := plugin.(interface{ Close() error }); ok {
+			if err := closer.Close(); err != nil {
+				log.Error("Could not close plugin", "plugin", plugin.Name(), "error", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,269 @@
+package plugin
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/NaiKiDEV/ssh-chat/internal/model"
+	"github.com/charmbracelet/log"
+)
+
+// Out-of-process plugin protocol, one JSON object per line over the stdio of
+// the subprocess. Requests carrying an id expect a response with the same id.
+//
+//	-> {"id":1,"type":"init"}
+//	<- {"id":1,"commands":[{"name":"remind","usage":"/remind 10m text"}],"filter":false,"observe":true}
+//	-> {"id":2,"type":"filter","room":"public","message":{...}}
+//	<- {"id":2,"message":{...}}  or  {"id":2,"error":"reason"}
+//	-> {"type":"observe","room":"public","message":{...}}
+//	-> {"id":3,"type":"command","command":{"room":"public","username":"alice","name":"remind","args":["10m","stand-up"]}}
+//	<- {"id":3,"replies":["reminder set"]}  or  {"id":3,"error":"reason"}
+//
+// At any time the plugin may post on its own:
+//
+//	<- {"type":"post","room":"public","text":"stand-up time!"}
+
+const (
+	processRequestTimeout = 2 * time.Second
+	processStopTimeout    = 5 * time.Second
+)
+
+var errProcessExited = errors.New("plugin process exited")
+
+type processMessage struct {
+	Id      int64          `json:"id,omitempty"`
+	Type    string         `json:"type,omitempty"`
+	Room    string         `json:"room,omitempty"`
+	Text    string         `json:"text,omitempty"`
+	Message *model.Message `json:"message,omitempty"`
+	Command *CommandCall   `json:"command,omitempty"`
+
+	// Responses only
+	Commands []Command `json:"commands,omitempty"`
+	Filter   bool      `json:"filter,omitempty"`
+	Observe  bool      `json:"observe,omitempty"`
+	Replies  []string  `json:"replies,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// Process is a plugin running as a subprocess
+type Process struct {
+	name string
+	cmd  *exec.Cmd
+
+	writeMutex sync.Mutex
+	stdin      io.WriteCloser
+	encoder    *json.Encoder
+
+	mutex   sync.Mutex
+	nextId  int64
+	pending map[int64]chan processMessage
+	exited  bool
+	host    Host
+
+	commands []Command
+	filter   bool
+	observe  bool
+}
+
+func StartProcess(name string, command []string) (*Process, error) {
+	if len(command) == 0 {
+		return nil, fmt.Errorf("plugin %s has no command", name)
+	}
+
+	cmd := exec.Command(command[0], command[1:]...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	cmd.Stderr = log.StandardLog(log.StandardLogOptions{ForceLevel: log.WarnLevel}).Writer()
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("could not start plugin %s: %w", name, err)
+	}
+
+	p := &Process{
+		name:    name,
+		cmd:     cmd,
+		stdin:   stdin,
+		encoder: json.NewEncoder(stdin),
+		pending: map[int64]chan processMessage{},
+	}
+	go p.read(stdout)
+	return p, nil
+}
+
+func (p *Process) Name() string {
+	return p.name
+}
+
+func (p *Process) Init(host Host) error {
+	p.mutex.Lock()
+	p.host = host
+	p.mutex.Unlock()
+
+	response, err := p.request(processMessage{Type: "init"})
+	if err != nil {
+		return err
+	}
+	p.commands = response.Commands
+	p.filter = response.Filter
+	p.observe = response.Observe
+	return nil
+}
+
+func (p *Process) Commands() []Command {
+	return p.commands
+}
+
+func (p *Process) FilterMessage(roomId string, msg model.Message) (model.Message, error) {
+	if !p.filter {
+		return msg, nil
+	}
+
+	response, err := p.request(processMessage{Type: "filter", Room: roomId, Message: &msg})
+	if err != nil {
+		// A broken filter must not take the whole chat down with it
+		log.Error("Plugin filter failed, letting message through", "plugin", p.name, "error", err)
+		return msg, nil
+	}
+	if response.Error != "" {
+		return msg, errors.New(response.Error)
+	}
+	if response.Message == nil {
+		return msg, nil
+	}
+	return *response.Message, nil
+}
+
+func (p *Process) MessagePosted(roomId string, msg model.Message) {
+	if !p.observe {
+		return
+	}
+	if err := p.send(processMessage{Type: "observe", Room: roomId, Message: &msg}); err != nil {
+		log.Error("Could not notify plugin", "plugin", p.name, "error", err)
+	}
+}
+
+func (p *Process) HandleCommand(call CommandCall) ([]string, error) {
+	response, err := p.request(processMessage{Type: "command", Command: &call})
+	if err != nil {
+		return nil, err
+	}
+	if response.Error != "" {
+		return nil, errors.New(response.Error)
+	}
+	return response.Replies, nil
+}
+
+// Close asks the plugin to exit by closing its stdin, it is killed if it
+// doesn't within processStopTimeout
+func (p *Process) Close() error {
+	_ = p.stdin.Close()
+
+	exited := make(chan error, 1)
+	go func() { exited <- p.cmd.Wait() }()
+
+	select {
+	case err := <-exited:
+		return err
+	case <-time.After(processStopTimeout):
+		return p.cmd.Process.Kill()
+	}
+}
+
+func (p *Process) send(msg processMessage) error {
+	p.writeMutex.Lock()
+	defer p.writeMutex.Unlock()
+	return p.encoder.Encode(msg)
+}
+
+func (p *Process) request(msg processMessage) (processMessage, error) {
+	p.mutex.Lock()
+	if p.exited {
+		p.mutex.Unlock()
+		return processMessage{}, errProcessExited
+	}
+	p.nextId++
+	msg.Id = p.nextId
+	responses := make(chan processMessage, 1)
+	p.pending[msg.Id] = responses
+	p.mutex.Unlock()
+
+	defer func() {
+		p.mutex.Lock()
+		delete(p.pending, msg.Id)
+		p.mutex.Unlock()
+	}()
+
+	if err := p.send(msg); err != nil {
+		return processMessage{}, err
+	}
+
+	select {
+	case response, ok := <-responses:
+		if !ok {
+			return processMessage{}, errProcessExited
+		}
+		return response, nil
+	case <-time.After(processRequestTimeout):
+		return processMessage{}, fmt.Errorf("plugin %s did not answer %s in time", p.name, msg.Type)
+	}
+}
+
+func (p *Process) read(stdout io.Reader) {
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var msg processMessage
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			log.Warn("Invalid plugin output", "plugin", p.name, "error", err)
+			continue
+		}
+
+		if msg.Type == "post" {
+			p.mutex.Lock()
+			host := p.host
+			p.mutex.Unlock()
+			if host == nil {
+				continue
+			}
+			// Posting runs this plugin's own filter, which needs the reader
+			go func() {
+				if err := host.PostMessage(p.name, msg.Room, msg.Text); err != nil {
+					log.Warn("Plugin could not post", "plugin", p.name, "error", err)
+				}
+			}()
+			continue
+		}
+
+		p.mutex.Lock()
+		if responses, ok := p.pending[msg.Id]; ok {
+			select {
+			case responses <- msg:
+			default:
+				// Duplicate response to the same request
+			}
+		}
+		p.mutex.Unlock()
+	}
+
+	log.Warn("Plugin process exited", "plugin", p.name)
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.exited = true
+	for id, responses := range p.pending {
+		close(responses)
+		delete(p.pending, id)
+	}
+}
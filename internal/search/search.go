@@ -0,0 +1,217 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/NaiKiDEV/ssh-chat/internal/model"
+)
+
+// Result is a matched message with the position it has in its room history
+type Result struct {
+	RoomId   string
+	Position int
+	Message  model.Message
+}
+
+// Query is a parsed search, every set field has to match
+type Query struct {
+	// Words the message text has to contain, in any order
+	Terms []string
+	// Author, compared case-insensitively
+	From string
+	// Room id
+	In string
+	// Messages sent before Before and at or after After
+	Before time.Time
+	After  time.Time
+}
+
+var ErrEmptyQuery = errors.New("search for at least one word or filter")
+
+// Layouts accepted by before: and after:, dates are midnight UTC
+var timeLayouts = []string{time.RFC3339, "2006-01-02T15:04", "2006-01-02"}
+
+// ParseQuery splits text into words and the from:, in:, before: and after:
+// filters, e.g. "deploy from:alice in:ops after:2024-05-01"
+func ParseQuery(text string) (Query, error) {
+	query := Query{}
+	words := []string{}
+	for _, field := range strings.Fields(text) {
+		key, value, ok := strings.Cut(field, ":")
+		if !ok || value == "" {
+			words = append(words, field)
+			continue
+		}
+
+		var err error
+		switch strings.ToLower(key) {
+		case "from":
+			query.From = strings.TrimPrefix(value, "@")
+		case "in":
+			query.In = strings.TrimPrefix(value, "#")
+		case "before":
+			query.Before, err = parseTime(value)
+		case "after":
+			query.After, err = parseTime(value)
+		default:
+			words = append(words, field)
+		}
+		if err != nil {
+			return Query{}, fmt.Errorf("%s: %w", key, err)
+		}
+	}
+
+	query.Terms = Tokenize(strings.Join(words, " "))
+	if len(query.Terms) == 0 && query.From == "" && query.In == "" && query.Before.IsZero() && query.After.IsZero() {
+		return Query{}, ErrEmptyQuery
+	}
+	return query, nil
+}
+
+func parseTime(value string) (time.Time, error) {
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q, use YYYY-MM-DD or RFC 3339", value)
+}
+
+// Tokenize lower cases text and splits it into words of letters and digits
+func Tokenize(text string) []string {
+	tokens := []string{}
+	for _, span := range tokenSpans(text) {
+		tokens = append(tokens, strings.ToLower(text[span[0]:span[1]]))
+	}
+	return tokens
+}
+
+// MatchRanges returns the byte ranges of the words of text that are one of
+// terms, used to highlight what a search matched
+func MatchRanges(text string, terms []string) [][2]int {
+	ranges := [][2]int{}
+	for _, span := range tokenSpans(text) {
+		if slices.Contains(terms, strings.ToLower(text[span[0]:span[1]])) {
+			ranges = append(ranges, span)
+		}
+	}
+	return ranges
+}
+
+func tokenSpans(text string) [][2]int {
+	spans := [][2]int{}
+	start := -1
+	for i, r := range text {
+		isWordRune := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if isWordRune && start == -1 {
+			start = i
+		} else if !isWordRune && start != -1 {
+			spans = append(spans, [2]int{start, i})
+			start = -1
+		}
+	}
+	if start != -1 {
+		spans = append(spans, [2]int{start, len(text)})
+	}
+	return spans
+}
+
+// Index is an inverted index over every message posted since the start, it
+// is safe for concurrent use
+type Index struct {
+	mutex sync.RWMutex
+
+	documents []Result
+	// Word to the ascending ids of the documents containing it
+	postings map[string][]int
+}
+
+func NewIndex() *Index {
+	return &Index{
+		postings: map[string][]int{},
+	}
+}
+
+func (i *Index) Add(roomId string, position int, msg model.Message) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	id := len(i.documents)
+	i.documents = append(i.documents, Result{
+		RoomId:   roomId,
+		Position: position,
+		Message:  msg,
+	})
+
+	for _, token := range Tokenize(msg.Text) {
+		// A document is appended once per word, ids only grow
+		if ids := i.postings[token]; len(ids) == 0 || ids[len(ids)-1] != id {
+			i.postings[token] = append(ids, id)
+		}
+	}
+}
+
+// Search returns up to limit matches, newest first
+func (i *Index) Search(query Query, limit int) []Result {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	results := []Result{}
+	matches := func(id int) bool {
+		doc := i.documents[id]
+		switch {
+		case query.From != "" && !strings.EqualFold(doc.Message.Username, query.From):
+			return false
+		case query.In != "" && doc.RoomId != query.In:
+			return false
+		case !query.Before.IsZero() && !doc.Message.Timestamp.Before(query.Before):
+			return false
+		case !query.After.IsZero() && doc.Message.Timestamp.Before(query.After):
+			return false
+		}
+		return true
+	}
+
+	if len(query.Terms) == 0 {
+		for id := len(i.documents) - 1; id >= 0 && len(results) < limit; id-- {
+			if matches(id) {
+				results = append(results, i.documents[id])
+			}
+		}
+		return results
+	}
+
+	ids := i.postings[query.Terms[0]]
+	for _, term := range query.Terms[1:] {
+		ids = intersect(ids, i.postings[term])
+	}
+	for idx := len(ids) - 1; idx >= 0 && len(results) < limit; idx-- {
+		if matches(ids[idx]) {
+			results = append(results, i.documents[ids[idx]])
+		}
+	}
+	return results
+}
+
+// Both lists have to be sorted ascending
+func intersect(a []int, b []int) []int {
+	result := []int{}
+	for len(a) > 0 && len(b) > 0 {
+		switch {
+		case a[0] == b[0]:
+			result = append(result, a[0])
+			a, b = a[1:], b[1:]
+		case a[0] < b[0]:
+			a = a[1:]
+		default:
+			b = b[1:]
+		}
+	}
+	return result
+}
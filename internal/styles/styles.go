@@ -1,6 +1,9 @@
 package styles
 
-import "github.com/charmbracelet/lipgloss"
+import (
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
 
 type ClientStyles struct {
 	RegularTxt     lipgloss.Style
@@ -9,11 +12,28 @@ type ClientStyles struct {
 	Button         lipgloss.Style
 	ActiveButton   lipgloss.Style
 	DialogBox      lipgloss.Style
+	// Badge next to messages posted by integrations
+	IntegrationBadge lipgloss.Style
+	// Words matched by a search
+	SearchHighlight lipgloss.Style
+	// URLs in messages
+	Link lipgloss.Style
+	// Fenced code blocks in messages
+	CodeBlock lipgloss.Style
+	// Chroma formatter and style highlighting code blocks, an empty formatter
+	// leaves code uncolored
+	CodeFormatter string
+	CodeTheme     string
+	// Links are wrapped in OSC 8 sequences, only set for terminals known to
+	// support them
+	Hyperlinks bool
 
 	PrimaryColor lipgloss.Color
 	GreyColor    lipgloss.Color
 	MutedColor   lipgloss.Color
 	ErrorColor   lipgloss.Color
+	// Author label of messages posted by integrations
+	IntegrationColor lipgloss.Color
 }
 
 func NewClientStyles(renderer *lipgloss.Renderer) *ClientStyles {
@@ -25,6 +45,7 @@ func NewClientStyles(renderer *lipgloss.Renderer) *ClientStyles {
 	greyColor := lipgloss.Color("#888B7E")
 	mutedColor := lipgloss.Color("240")
 	errorColor := lipgloss.Color("#FF0000")
+	integrationColor := lipgloss.Color("#7D56F4")
 
 	buttonStyle := lipgloss.NewStyle().
 		Foreground(lipgloss.Color("#FFF7DB")).
@@ -43,6 +64,30 @@ func NewClientStyles(renderer *lipgloss.Renderer) *ClientStyles {
 		BorderRight(true).
 		BorderBottom(true)
 
+	integrationBadgeStyle := renderer.NewStyle().
+		Foreground(lipgloss.Color("#FFF7DB")).
+		Background(integrationColor).
+		Padding(0, 1)
+
+	searchHighlightStyle := renderer.NewStyle().
+		Foreground(lipgloss.Color("#FFF7DB")).
+		Background(primaryColor)
+
+	linkStyle := renderer.NewStyle().
+		Foreground(lipgloss.Color("#5FAFFF")).
+		Underline(true)
+
+	codeBlockStyle := renderer.NewStyle().
+		BorderStyle(lipgloss.NormalBorder()).
+		BorderLeft(true).
+		BorderForeground(mutedColor).
+		PaddingLeft(1)
+
+	codeTheme := "github"
+	if renderer.HasDarkBackground() {
+		codeTheme = "monokai"
+	}
+
 	return &ClientStyles{
 		RegularTxt:     txtStyle,
 		BoldRegularTxt: boldTxtStyle,
@@ -51,9 +96,30 @@ func NewClientStyles(renderer *lipgloss.Renderer) *ClientStyles {
 		ActiveButton:   activeButtonStyle,
 		DialogBox:      dialogBoxStyle,
 
+		IntegrationBadge: integrationBadgeStyle,
+		SearchHighlight:  searchHighlightStyle,
+		Link:             linkStyle,
+		CodeBlock:        codeBlockStyle,
+		CodeFormatter:    codeFormatter(renderer.ColorProfile()),
+		CodeTheme:        codeTheme,
+
 		PrimaryColor: primaryColor,
 		GreyColor:    greyColor,
 		MutedColor:   mutedColor,
 		ErrorColor:   errorColor,
+
+		IntegrationColor: integrationColor,
+	}
+}
+
+func codeFormatter(profile termenv.Profile) string {
+	switch profile {
+	case termenv.TrueColor:
+		return "terminal16m"
+	case termenv.ANSI256:
+		return "terminal256"
+	case termenv.ANSI:
+		return "terminal16"
 	}
+	return ""
 }
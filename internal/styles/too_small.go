@@ -0,0 +1,17 @@
+package styles
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// RenderTooSmall fills a width×height terminal with a notice, shown by views
+// in place of a layout needing minWidth×minHeight
+func (s *ClientStyles) RenderTooSmall(width int, height int, minWidth int, minHeight int) string {
+	text := lipgloss.JoinVertical(lipgloss.Center,
+		s.BoldRegularTxt.Foreground(s.PrimaryColor).Render("Terminal too small"),
+		s.RegularTxt.Foreground(s.MutedColor).Render(fmt.Sprintf("%d×%d, needs %d×%d", width, height, minWidth, minHeight)),
+	)
+	return lipgloss.Place(width, height, lipgloss.Center, lipgloss.Center, text)
+}
@@ -5,3 +5,16 @@ type TerminalState struct {
 	Height int
 	Theme  string
 }
+
+// Area is a rectangle of cells on the screen, used to tell what a mouse click
+// landed on
+type Area struct {
+	X      int
+	Y      int
+	Width  int
+	Height int
+}
+
+func (a Area) Contains(x int, y int) bool {
+	return x >= a.X && x < a.X+a.Width && y >= a.Y && y < a.Y+a.Height
+}
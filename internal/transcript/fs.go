@@ -0,0 +1,233 @@
+package transcript
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/NaiKiDEV/ssh-chat/internal/model"
+)
+
+// Directory holding a subdirectory per room
+const RoomsDir = "rooms"
+
+// Subdirectory of a room serving Source.Files
+const FilesDir = "files"
+
+// Source provides the rooms served by FS
+type Source interface {
+	RoomIds() []string
+	// Messages returns the history of a room, false when it does not exist
+	Messages(roomId string) ([]model.Message, bool)
+	// Files returns what is served in rooms/<room>/files, nil for nothing
+	Files(roomId string) fs.FS
+}
+
+// FS is a read-only file system with one transcript per room, day and
+// format: rooms/<room>/<YYYY-MM-DD>.<md|json|txt>, next to the files shared
+// in the room: rooms/<room>/files/<name>. Transcripts are rendered when
+// opened, nothing is kept around.
+type FS struct {
+	source Source
+}
+
+var _ fs.FS = FS{}
+
+func NewFS(source Source) FS {
+	return FS{source: source}
+}
+
+func (f FS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	notExist := &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+
+	if name == "." {
+		return newDir(".", time.Time{}, []fs.FileInfo{dirInfo(RoomsDir, time.Time{})}), nil
+	}
+
+	parts := strings.Split(name, "/")
+	if parts[0] != RoomsDir {
+		return nil, notExist
+	}
+
+	if len(parts) == 1 {
+		entries := []fs.FileInfo{}
+		for _, roomId := range f.source.RoomIds() {
+			messages, _ := f.source.Messages(roomId)
+			entries = append(entries, dirInfo(roomId, lastModified(messages)))
+		}
+		return newDir(RoomsDir, time.Time{}, entries), nil
+	}
+
+	roomId := parts[1]
+	messages, ok := f.source.Messages(roomId)
+	if !ok {
+		return nil, notExist
+	}
+
+	if len(parts) > 2 && parts[2] == FilesDir {
+		return f.openFile(roomId, parts[3:])
+	}
+	if len(parts) > 3 {
+		return nil, notExist
+	}
+
+	if len(parts) == 2 {
+		entries := []fs.FileInfo{}
+		if f.source.Files(roomId) != nil {
+			entries = append(entries, dirInfo(FilesDir, lastModified(messages)))
+		}
+		for _, day := range SplitDays(messages) {
+			for _, ext := range sortedExtensions() {
+				info, err := renderFile(roomId, day, ext)
+				if err != nil {
+					return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+				}
+				entries = append(entries, info)
+			}
+		}
+		return newDir(roomId, lastModified(messages), entries), nil
+	}
+
+	date, ext, ok := ParseFileName(parts[2])
+	if !ok {
+		return nil, notExist
+	}
+	for _, day := range SplitDays(messages) {
+		if day.Date.Equal(date) {
+			info, err := renderFile(roomId, day, ext)
+			if err != nil {
+				return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+			}
+			return &file{info: info, Reader: bytes.NewReader(info.data)}, nil
+		}
+	}
+	return nil, notExist
+}
+
+// Opens a shared file, hidden files are neither listed nor served
+func (f FS) openFile(roomId string, parts []string) (fs.File, error) {
+	name := path.Join(append([]string{RoomsDir, roomId, FilesDir}, parts...)...)
+	files := f.source.Files(roomId)
+	if files == nil || len(parts) > 1 || (len(parts) == 1 && strings.HasPrefix(parts[0], ".")) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+
+	if len(parts) == 1 {
+		return files.Open(parts[0])
+	}
+
+	entries, err := fs.ReadDir(files, ".")
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	infos := []fs.FileInfo{}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") || !entry.Type().IsRegular() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		infos = append(infos, info)
+	}
+	return newDir(FilesDir, time.Time{}, infos), nil
+}
+
+func sortedExtensions() []string {
+	extensions := make([]string, 0, len(Formats))
+	for ext := range Formats {
+		extensions = append(extensions, ext)
+	}
+	slices.Sort(extensions)
+	return extensions
+}
+
+func lastModified(messages []model.Message) time.Time {
+	if len(messages) == 0 {
+		return time.Time{}
+	}
+	return messages[len(messages)-1].Timestamp
+}
+
+func renderFile(roomId string, day Day, ext string) (fileInfo, error) {
+	data, err := Formats[ext](roomId, day.Date, day.Messages)
+	if err != nil {
+		return fileInfo{}, err
+	}
+	return fileInfo{
+		name:    FileName(day.Date, ext),
+		mode:    0o444,
+		modTime: lastModified(day.Messages),
+		data:    data,
+	}, nil
+}
+
+type fileInfo struct {
+	name    string
+	mode    fs.FileMode
+	modTime time.Time
+	data    []byte
+}
+
+func dirInfo(name string, modTime time.Time) fileInfo {
+	return fileInfo{name: name, mode: fs.ModeDir | 0o555, modTime: modTime}
+}
+
+func (i fileInfo) Name() string       { return i.name }
+func (i fileInfo) Size() int64        { return int64(len(i.data)) }
+func (i fileInfo) Mode() fs.FileMode  { return i.mode }
+func (i fileInfo) ModTime() time.Time { return i.modTime }
+func (i fileInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i fileInfo) Sys() any           { return nil }
+
+// file also implements io.ReaderAt and io.Seeker through the bytes.Reader
+type file struct {
+	info fileInfo
+	*bytes.Reader
+}
+
+func (f *file) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *file) Close() error               { return nil }
+
+type dir struct {
+	info    fileInfo
+	entries []fs.FileInfo
+	offset  int
+}
+
+func newDir(name string, modTime time.Time, entries []fs.FileInfo) *dir {
+	return &dir{info: dirInfo(name, modTime), entries: entries}
+}
+
+func (d *dir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *dir) Close() error               { return nil }
+
+func (d *dir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.name, Err: fs.ErrInvalid}
+}
+
+func (d *dir) ReadDir(count int) ([]fs.DirEntry, error) {
+	remaining := d.entries[d.offset:]
+	if count > 0 {
+		if len(remaining) == 0 {
+			return nil, io.EOF
+		}
+		remaining = remaining[:min(count, len(remaining))]
+	}
+	d.offset += len(remaining)
+
+	entries := make([]fs.DirEntry, 0, len(remaining))
+	for _, info := range remaining {
+		entries = append(entries, fs.FileInfoToDirEntry(info))
+	}
+	return entries, nil
+}
@@ -0,0 +1,114 @@
+package transcript
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/NaiKiDEV/ssh-chat/internal/model"
+)
+
+// Layout of the day part of transcript file names, days are in UTC
+const dayLayout = "2006-01-02"
+
+// Format renders the messages a room received on one day
+type Format func(roomId string, day time.Time, messages []model.Message) ([]byte, error)
+
+// Formats keyed by the file extension they are served with
+var Formats = map[string]Format{
+	".md":   Markdown,
+	".json": JSON,
+	".txt":  Plain,
+}
+
+// Day is the part of a room history a single transcript file covers
+type Day struct {
+	Date     time.Time
+	Messages []model.Message
+}
+
+// SplitDays groups messages by the UTC day they were sent on, oldest first
+func SplitDays(messages []model.Message) []Day {
+	days := []Day{}
+	// Clock adjustments can put timestamps out of posting order
+	dayIndex := map[time.Time]int{}
+	for _, msg := range messages {
+		date := truncateDay(msg.Timestamp)
+		idx, ok := dayIndex[date]
+		if !ok {
+			idx = len(days)
+			dayIndex[date] = idx
+			days = append(days, Day{Date: date})
+		}
+		days[idx].Messages = append(days[idx].Messages, msg)
+	}
+	slices.SortFunc(days, func(a, b Day) int { return a.Date.Compare(b.Date) })
+	return days
+}
+
+// FileName of the transcript of day in the given format extension
+func FileName(day time.Time, ext string) string {
+	return day.Format(dayLayout) + ext
+}
+
+// ParseFileName is the inverse of FileName
+func ParseFileName(name string) (time.Time, string, bool) {
+	ext := path.Ext(name)
+	if _, ok := Formats[ext]; !ok {
+		return time.Time{}, "", false
+	}
+	day, err := time.Parse(dayLayout, strings.TrimSuffix(name, ext))
+	if err != nil {
+		return time.Time{}, "", false
+	}
+	return day, ext, true
+}
+
+func truncateDay(t time.Time) time.Time {
+	year, month, day := t.UTC().Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func Markdown(roomId string, day time.Time, messages []model.Message) ([]byte, error) {
+	transcript := strings.Builder{}
+	transcript.WriteString(fmt.Sprintf("# %s, %s\n", roomId, day.Format(dayLayout)))
+	for _, msg := range messages {
+		transcript.WriteString(fmt.Sprintf("\n**%s** (%s UTC):  \n", msg.DisplayName(), msg.Timestamp.UTC().Format(time.TimeOnly)))
+		// Trailing double spaces keep the line breaks of multi-line messages
+		transcript.WriteString(strings.Join(strings.Split(msg.Text, "\n"), "  \n") + "\n")
+		if msg.Attachment != nil {
+			// Transcripts are next to the files directory, link relative to it
+			link := path.Join(FilesDir, path.Base(msg.Attachment.Path))
+			transcript.WriteString(fmt.Sprintf("\n[%s](%s)\n", msg.Attachment.Summary(), link))
+		}
+	}
+	return []byte(transcript.String()), nil
+}
+
+func JSON(roomId string, day time.Time, messages []model.Message) ([]byte, error) {
+	data, err := json.MarshalIndent(struct {
+		RoomId   string          `json:"room"`
+		Date     string          `json:"date"`
+		Messages []model.Message `json:"messages"`
+	}{
+		RoomId:   roomId,
+		Date:     day.Format(dayLayout),
+		Messages: messages,
+	}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+func Plain(roomId string, day time.Time, messages []model.Message) ([]byte, error) {
+	transcript := strings.Builder{}
+	for _, msg := range messages {
+		lines := strings.Split(msg.PlainText(), "\n")
+		transcript.WriteString(fmt.Sprintf("[%s] %s: %s\n", msg.Timestamp.UTC().Format(time.TimeOnly), msg.DisplayName(), strings.Join(lines, "\n  ")))
+	}
+	return []byte(transcript.String()), nil
+}
@@ -0,0 +1,308 @@
+// Package webhook delivers room events to operator configured HTTP endpoints.
+//
+// Every delivery is a POST with the JSON encoded event as body, signed with
+// HMAC-SHA256 over "<timestamp>.<body>" using the endpoint secret:
+//
+//	X-Chat-Event:     message | join | leave
+//	X-Chat-Delivery:  unique delivery id, stable across retries
+//	X-Chat-Timestamp: unix seconds the signature was computed at
+//	X-Chat-Signature: sha256=<hex digest>
+//
+// Network errors, 429 and 5xx responses are retried with exponential backoff,
+// deliveries that still fail end up in the dead-letter log.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	cryptorand "crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/NaiKiDEV/ssh-chat/internal/model"
+	"github.com/charmbracelet/log"
+)
+
+const (
+	SignatureHeader = "X-Chat-Signature"
+	TimestampHeader = "X-Chat-Timestamp"
+	EventHeader     = "X-Chat-Event"
+	DeliveryHeader  = "X-Chat-Delivery"
+)
+
+type Endpoint struct {
+	URL    string
+	Secret string
+}
+
+type Options struct {
+	// Used for deliveries, tests can point it at a local stand-in
+	Client *http.Client
+	// Attempts per delivery including the first one
+	MaxAttempts int
+	// Delay before the first retry, doubled for every following one
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Workers        int
+	QueueSize      int
+	// JSON lines file failed deliveries are appended to, empty disables it
+	DeadLetterPath string
+}
+
+func DefaultOptions() Options {
+	return Options{
+		Client:         &http.Client{Timeout: 10 * time.Second},
+		MaxAttempts:    5,
+		InitialBackoff: time.Second,
+		MaxBackoff:     time.Minute,
+		Workers:        4,
+		QueueSize:      1024,
+	}
+}
+
+type delivery struct {
+	id       string
+	endpoint Endpoint
+	event    model.Event
+	body     []byte
+	attempt  int
+}
+
+type deadLetter struct {
+	Time     time.Time       `json:"time"`
+	Delivery string          `json:"delivery"`
+	URL      string          `json:"url"`
+	Attempts int             `json:"attempts"`
+	Error    string          `json:"error"`
+	Payload  json.RawMessage `json:"payload"`
+}
+
+type Dispatcher struct {
+	options Options
+	queue   chan delivery
+	workers sync.WaitGroup
+
+	mutex   sync.Mutex
+	closed  bool
+	retries map[*time.Timer]delivery
+
+	deadLetterMutex sync.Mutex
+}
+
+func NewDispatcher(options Options) *Dispatcher {
+	d := &Dispatcher{
+		options: options,
+		queue:   make(chan delivery, options.QueueSize),
+		retries: map[*time.Timer]delivery{},
+	}
+	for range options.Workers {
+		d.workers.Add(1)
+		go d.work()
+	}
+	return d
+}
+
+// Dispatch queues the event for delivery to the endpoint, it never blocks
+func (d *Dispatcher) Dispatch(endpoint Endpoint, event model.Event) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		log.Error("Could not encode webhook payload", "error", err)
+		return
+	}
+
+	d.enqueue(delivery{
+		id:       newDeliveryId(),
+		endpoint: endpoint,
+		event:    event,
+		body:     body,
+	})
+}
+
+// Close stops accepting deliveries and waits for the queued ones until ctx
+// expires, whatever is left over is dead-lettered
+func (d *Dispatcher) Close(ctx context.Context) {
+	d.mutex.Lock()
+	if d.closed {
+		d.mutex.Unlock()
+		return
+	}
+	d.closed = true
+	// Retries still in the map have not been picked up by their timer yet
+	for timer, pending := range d.retries {
+		timer.Stop()
+		d.writeDeadLetter(pending, "server shutting down")
+	}
+	clear(d.retries)
+	close(d.queue)
+	d.mutex.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		d.workers.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		log.Warn("Webhook deliveries still pending at shutdown")
+	}
+}
+
+func (d *Dispatcher) enqueue(pending delivery) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.closed {
+		d.writeDeadLetter(pending, "server shutting down")
+		return
+	}
+
+	select {
+	case d.queue <- pending:
+	default:
+		d.writeDeadLetter(pending, "delivery queue full")
+	}
+}
+
+func (d *Dispatcher) work() {
+	defer d.workers.Done()
+	for pending := range d.queue {
+		d.deliver(pending)
+	}
+}
+
+func (d *Dispatcher) deliver(pending delivery) {
+	pending.attempt++
+
+	retry, err := d.send(pending)
+	if err == nil {
+		return
+	}
+
+	if !retry || pending.attempt >= d.options.MaxAttempts {
+		log.Warn("Webhook delivery failed", "url", pending.endpoint.URL, "attempts", pending.attempt, "error", err)
+		d.writeDeadLetter(pending, err.Error())
+		return
+	}
+
+	backoff := d.backoff(pending.attempt)
+	log.Debug("Retrying webhook delivery", "url", pending.endpoint.URL, "attempt", pending.attempt, "in", backoff, "error", err)
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.closed {
+		d.writeDeadLetter(pending, err.Error())
+		return
+	}
+
+	var timer *time.Timer
+	timer = time.AfterFunc(backoff, func() {
+		d.mutex.Lock()
+		_, scheduled := d.retries[timer]
+		delete(d.retries, timer)
+		d.mutex.Unlock()
+
+		if scheduled {
+			d.enqueue(pending)
+		}
+	})
+	d.retries[timer] = pending
+}
+
+// Reports whether a failed delivery is worth retrying
+func (d *Dispatcher) send(pending delivery) (bool, error) {
+	request, err := http.NewRequest(http.MethodPost, pending.endpoint.URL, bytes.NewReader(pending.body))
+	if err != nil {
+		return false, err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(EventHeader, pending.event.Kind)
+	request.Header.Set(DeliveryHeader, pending.id)
+	request.Header.Set(TimestampHeader, timestamp)
+	request.Header.Set(SignatureHeader, Sign(pending.endpoint.Secret, timestamp, pending.body))
+
+	response, err := d.options.Client.Do(request)
+	if err != nil {
+		return true, err
+	}
+	defer response.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, 64*1024))
+
+	if response.StatusCode >= 200 && response.StatusCode < 300 {
+		return false, nil
+	}
+
+	retry := response.StatusCode == http.StatusTooManyRequests || response.StatusCode >= 500
+	return retry, fmt.Errorf("unexpected status %s", response.Status)
+}
+
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	backoff := d.options.InitialBackoff << (attempt - 1)
+	if backoff <= 0 || backoff > d.options.MaxBackoff {
+		backoff = d.options.MaxBackoff
+	}
+	// Up to 20% jitter so failing endpoints don't get hit in lockstep
+	return backoff + rand.N(backoff/5+1)
+}
+
+func (d *Dispatcher) writeDeadLetter(pending delivery, reason string) {
+	if d.options.DeadLetterPath == "" {
+		return
+	}
+
+	d.deadLetterMutex.Lock()
+	defer d.deadLetterMutex.Unlock()
+
+	line, err := json.Marshal(deadLetter{
+		Time:     time.Now(),
+		Delivery: pending.id,
+		URL:      pending.endpoint.URL,
+		Attempts: pending.attempt,
+		Error:    reason,
+		Payload:  pending.body,
+	})
+	if err != nil {
+		log.Error("Could not encode dead letter", "error", err)
+		return
+	}
+
+	file, err := os.OpenFile(d.options.DeadLetterPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		log.Error("Could not open dead-letter log", "error", err)
+		return
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		log.Error("Could not write dead-letter log", "error", err)
+	}
+}
+
+// Sign computes the signature header value for a payload, receivers compute
+// the same over the received body and compare in constant time
+func Sign(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func newDeliveryId() string {
+	id := make([]byte, 16)
+	_, _ = cryptorand.Read(id)
+	return hex.EncodeToString(id)
+}
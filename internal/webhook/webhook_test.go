@@ -0,0 +1,261 @@
+package webhook
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/NaiKiDEV/ssh-chat/internal/model"
+)
+
+const testSecret = "s3cret"
+
+func testOptions(t *testing.T, server *httptest.Server) Options {
+	t.Helper()
+	return Options{
+		Client:         server.Client(),
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     5 * time.Millisecond,
+		Workers:        1,
+		QueueSize:      16,
+		DeadLetterPath: filepath.Join(t.TempDir(), "dead-letters.jsonl"),
+	}
+}
+
+func testEvent() model.Event {
+	return model.Event{
+		Kind:      "message",
+		RoomId:    "public",
+		Username:  "alice",
+		Message:   &model.Message{Username: "alice", Text: "hello"},
+		Timestamp: time.Unix(1700000000, 0).UTC(),
+	}
+}
+
+// Polls until condition holds, deliveries and retries run on their own goroutines
+func waitFor(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func readDeadLetters(t *testing.T, path string) []deadLetter {
+	t.Helper()
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var letters []deadLetter
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var letter deadLetter
+		if err := json.Unmarshal(scanner.Bytes(), &letter); err != nil {
+			t.Fatalf("bad dead-letter line %q: %v", scanner.Text(), err)
+		}
+		letters = append(letters, letter)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return letters
+}
+
+func TestDispatchSignsDelivery(t *testing.T) {
+	requests := make(chan *http.Request, 1)
+	bodies := make(chan []byte, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- r
+		bodies <- body
+	}))
+	defer server.Close()
+
+	options := testOptions(t, server)
+	d := NewDispatcher(options)
+	defer d.Close(context.Background())
+
+	event := testEvent()
+	d.Dispatch(Endpoint{URL: server.URL, Secret: testSecret}, event)
+
+	var request *http.Request
+	select {
+	case request = <-requests:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no delivery received")
+	}
+	body := <-bodies
+
+	if request.Method != http.MethodPost {
+		t.Errorf("method = %s, want POST", request.Method)
+	}
+	if got := request.Header.Get(EventHeader); got != event.Kind {
+		t.Errorf("%s = %q, want %q", EventHeader, got, event.Kind)
+	}
+	if request.Header.Get(DeliveryHeader) == "" {
+		t.Errorf("%s missing", DeliveryHeader)
+	}
+
+	timestamp := request.Header.Get(TimestampHeader)
+	if timestamp == "" {
+		t.Fatalf("%s missing", TimestampHeader)
+	}
+	signature := request.Header.Get(SignatureHeader)
+	if want := Sign(testSecret, timestamp, body); signature != want {
+		t.Errorf("%s = %q, want %q", SignatureHeader, signature, want)
+	}
+	if !strings.HasPrefix(signature, "sha256=") {
+		t.Errorf("%s = %q, want sha256= prefix", SignatureHeader, signature)
+	}
+	if Sign("other", timestamp, body) == signature {
+		t.Error("signature does not depend on the secret")
+	}
+
+	var received model.Event
+	if err := json.Unmarshal(body, &received); err != nil {
+		t.Fatalf("body is not an event: %v", err)
+	}
+	if received.RoomId != event.RoomId || received.Message == nil || received.Message.Text != "hello" {
+		t.Errorf("received event %+v, want %+v", received, event)
+	}
+}
+
+func TestDispatchRetriesServerErrors(t *testing.T) {
+	var attempts atomic.Int32
+	deliveries := map[string]bool{}
+	deliveryIds := make(chan string, 3)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		deliveryIds <- r.Header.Get(DeliveryHeader)
+		if attempts.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	options := testOptions(t, server)
+	d := NewDispatcher(options)
+	d.Dispatch(Endpoint{URL: server.URL, Secret: testSecret}, testEvent())
+
+	waitFor(t, "third attempt", func() bool { return attempts.Load() >= 3 })
+	d.Close(context.Background())
+
+	if got := attempts.Load(); got != 3 {
+		t.Errorf("attempts = %d, want 3", got)
+	}
+	close(deliveryIds)
+	for id := range deliveryIds {
+		deliveries[id] = true
+	}
+	if len(deliveries) != 1 {
+		t.Errorf("retries used %d delivery ids, want the same one", len(deliveries))
+	}
+	if letters := readDeadLetters(t, options.DeadLetterPath); len(letters) != 0 {
+		t.Errorf("dead letters = %+v, want none", letters)
+	}
+}
+
+func TestDispatchDeadLettersAfterMaxAttempts(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	options := testOptions(t, server)
+	d := NewDispatcher(options)
+	defer d.Close(context.Background())
+
+	event := testEvent()
+	d.Dispatch(Endpoint{URL: server.URL, Secret: testSecret}, event)
+
+	waitFor(t, "dead letter", func() bool { return len(readDeadLetters(t, options.DeadLetterPath)) > 0 })
+
+	if got := attempts.Load(); got != int32(options.MaxAttempts) {
+		t.Errorf("attempts = %d, want %d", got, options.MaxAttempts)
+	}
+
+	letters := readDeadLetters(t, options.DeadLetterPath)
+	if len(letters) != 1 {
+		t.Fatalf("dead letters = %d, want 1", len(letters))
+	}
+	letter := letters[0]
+	if letter.URL != server.URL {
+		t.Errorf("url = %q, want %q", letter.URL, server.URL)
+	}
+	if letter.Attempts != options.MaxAttempts {
+		t.Errorf("attempts = %d, want %d", letter.Attempts, options.MaxAttempts)
+	}
+	if !strings.Contains(letter.Error, "500") {
+		t.Errorf("error = %q, want the status", letter.Error)
+	}
+	want, _ := json.Marshal(event)
+	if string(letter.Payload) != string(want) {
+		t.Errorf("payload = %s, want %s", letter.Payload, want)
+	}
+}
+
+func TestCloseDeadLettersPendingRetries(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	options := testOptions(t, server)
+	// Long enough that the retry is still waiting when Close runs
+	options.InitialBackoff = time.Hour
+	options.MaxBackoff = time.Hour
+	d := NewDispatcher(options)
+
+	d.Dispatch(Endpoint{URL: server.URL, Secret: testSecret}, testEvent())
+
+	waitFor(t, "scheduled retry", func() bool {
+		d.mutex.Lock()
+		defer d.mutex.Unlock()
+		return len(d.retries) == 1
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	d.Close(ctx)
+
+	if got := attempts.Load(); got != 1 {
+		t.Errorf("attempts = %d, want 1", got)
+	}
+	if len(d.retries) != 0 {
+		t.Errorf("retries left after Close = %d", len(d.retries))
+	}
+
+	letters := readDeadLetters(t, options.DeadLetterPath)
+	if len(letters) != 1 {
+		t.Fatalf("dead letters = %d, want 1", len(letters))
+	}
+	if letters[0].Error != "server shutting down" {
+		t.Errorf("error = %q, want %q", letters[0].Error, "server shutting down")
+	}
+	if letters[0].Attempts != 1 {
+		t.Errorf("attempts = %d, want 1", letters[0].Attempts)
+	}
+}
@@ -0,0 +1,626 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NaiKiDEV/ssh-chat/internal/audit"
+	"github.com/NaiKiDEV/ssh-chat/internal/model"
+	"github.com/charmbracelet/log"
+)
+
+const (
+	ircServerName = "ssh-chat"
+	// Clients have to send something, at least a PONG, within this window
+	ircIdleTimeout = 5 * time.Minute
+	ircMaxLineSize = 4096
+	// Marks messages posted through the gateway so they never pass for the
+	// ones of SSH users
+	ircIntegration = "irc"
+)
+
+// Numeric replies used by the gateway
+const (
+	rplWelcome          = "001"
+	rplYourHost         = "002"
+	rplCreated          = "003"
+	rplMyInfo           = "004"
+	rplNoTopic          = "331"
+	rplTopic            = "332"
+	rplNameReply        = "353"
+	rplEndOfNames       = "366"
+	rplMotd             = "372"
+	rplMotdStart        = "375"
+	rplEndOfMotd        = "376"
+	errNoSuchNick       = "401"
+	errNoSuchChannel    = "403"
+	errCannotSendToChan = "404"
+	errUnknownCommand   = "421"
+	errNoMotd           = "422"
+	errNoNicknameGiven  = "431"
+	errErroneusNickname = "432"
+	errNicknameInUse    = "433"
+	errNotOnChannel     = "442"
+	errNotRegistered    = "451"
+	errNeedMoreParams   = "461"
+	errAlreadyRegistred = "462"
+)
+
+type ircMessage struct {
+	Command string
+	Params  []string
+}
+
+// Parses `[:prefix] COMMAND param... [:trailing]`, the prefix is ignored as
+// clients are not allowed to speak for others
+func parseIRCMessage(line string) ircMessage {
+	line = strings.TrimRight(line, "\r\n")
+	if strings.HasPrefix(line, ":") {
+		_, line, _ = strings.Cut(line, " ")
+	}
+
+	var trailing *string
+	if head, tail, ok := strings.Cut(line, " :"); ok {
+		line = head
+		trailing = &tail
+	}
+
+	fields := strings.Fields(line)
+	msg := ircMessage{}
+	if len(fields) > 0 {
+		msg.Command = strings.ToUpper(fields[0])
+		msg.Params = fields[1:]
+	}
+	if trailing != nil {
+		msg.Params = append(msg.Params, *trailing)
+	}
+	return msg
+}
+
+// Rooms are exposed as channels, `public` is `#public`
+func roomIdFromChannel(channel string) string {
+	return strings.TrimPrefix(channel, "#")
+}
+
+func channelFromRoomId(roomId string) string {
+	return "#" + roomId
+}
+
+// IRC gateway sharing rooms and presence with the SSH server
+type ircServer struct {
+	listener net.Listener
+
+	mutex   sync.Mutex
+	clients map[*ircClient]struct{}
+	nicks   map[string]*ircClient
+	closed  bool
+}
+
+func startIRCServer(addr string) (*ircServer, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	server := &ircServer{
+		listener: listener,
+		clients:  map[*ircClient]struct{}{},
+		nicks:    map[string]*ircClient{},
+	}
+	go server.serve()
+	return server, nil
+}
+
+func (s *ircServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Error("Could not accept IRC connection", "error", err)
+			}
+			return
+		}
+
+		client := &ircClient{
+			server:    s,
+			conn:      conn,
+			sessionId: newIRCSessionId(),
+			channels:  map[string]chan model.Event{},
+		}
+		s.mutex.Lock()
+		if s.closed {
+			s.mutex.Unlock()
+			_ = conn.Close()
+			return
+		}
+		s.clients[client] = struct{}{}
+		s.mutex.Unlock()
+
+		go client.serve()
+	}
+}
+
+func (s *ircServer) Close() error {
+	s.mutex.Lock()
+	s.closed = true
+	clients := make([]*ircClient, 0, len(s.clients))
+	for client := range s.clients {
+		clients = append(clients, client)
+	}
+	s.mutex.Unlock()
+
+	err := s.listener.Close()
+	for _, client := range clients {
+		client.sendError("Server shutting down")
+		_ = client.conn.Close()
+	}
+	return err
+}
+
+// Notice sends a server notice to every registered client
+func (s *ircServer) Notice(text string) {
+	s.mutex.Lock()
+	clients := make([]*ircClient, 0, len(s.nicks))
+	for _, client := range s.nicks {
+		clients = append(clients, client)
+	}
+	s.mutex.Unlock()
+
+	for _, client := range clients {
+		client.sendf(":%s NOTICE %s :%s", ircServerName, client.nick, text)
+	}
+}
+
+func (s *ircServer) claimNick(client *ircClient, nick string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	owner, taken := s.nicks[strings.ToLower(nick)]
+	if taken && owner != client {
+		return false
+	}
+	// Also rejects names online over SSH, they keep their names
+	if !taken && !serverState.users.ClaimIRCNick(nick, client.nick) {
+		return false
+	}
+	if client.nick != "" {
+		delete(s.nicks, strings.ToLower(client.nick))
+	}
+	s.nicks[strings.ToLower(nick)] = client
+	return true
+}
+
+func (s *ircServer) removeClient(client *ircClient) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.clients, client)
+	if owner := s.nicks[strings.ToLower(client.nick)]; owner == client {
+		delete(s.nicks, strings.ToLower(client.nick))
+		serverState.users.ReleaseIRCNick(client.nick)
+	}
+}
+
+type ircClient struct {
+	server *ircServer
+	conn   net.Conn
+	// Identifies the connection in the audit log
+	sessionId string
+
+	writeMutex sync.Mutex
+
+	// Only touched by the connection goroutine
+	nick       string
+	user       string
+	registered bool
+	channels   map[string]chan model.Event
+}
+
+func (c *ircClient) serve() {
+	defer c.disconnect()
+
+	scanner := bufio.NewScanner(c.conn)
+	scanner.Buffer(make([]byte, 512), ircMaxLineSize)
+	for {
+		_ = c.conn.SetReadDeadline(time.Now().Add(ircIdleTimeout))
+		if !scanner.Scan() {
+			return
+		}
+		msg := parseIRCMessage(scanner.Text())
+		if msg.Command == "" {
+			continue
+		}
+		if quit := c.handle(msg); quit {
+			return
+		}
+	}
+}
+
+func (c *ircClient) disconnect() {
+	for roomId := range c.channels {
+		c.part(roomId)
+	}
+	c.server.removeClient(c)
+	_ = c.conn.Close()
+}
+
+func (c *ircClient) prefix() string {
+	return fmt.Sprintf("%s!%s@%s", c.nick, c.user, ircServerName)
+}
+
+func (c *ircClient) send(line string) {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+
+	_ = c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	if _, err := c.conn.Write([]byte(line + "\r\n")); err != nil {
+		log.Debug("Could not write to IRC client", "error", err)
+	}
+}
+
+func (c *ircClient) sendf(format string, args ...any) {
+	c.send(fmt.Sprintf(format, args...))
+}
+
+func (c *ircClient) sendNumeric(numeric string, params ...string) {
+	nick := c.nick
+	if nick == "" {
+		nick = "*"
+	}
+	if len(params) > 0 {
+		last := len(params) - 1
+		params[last] = ":" + params[last]
+	}
+	c.sendf(":%s %s %s %s", ircServerName, numeric, nick, strings.Join(params, " "))
+}
+
+func (c *ircClient) sendError(reason string) {
+	c.sendf("ERROR :%s", reason)
+}
+
+// Reports whether the client asked to quit
+func (c *ircClient) handle(msg ircMessage) bool {
+	switch msg.Command {
+	case "CAP", "PASS", "MODE", "WHO":
+		// Capabilities, passwords and modes are not supported, ignore quietly
+		return false
+	case "PING":
+		c.sendf(":%s PONG %s :%s", ircServerName, ircServerName, strings.Join(msg.Params, " "))
+		return false
+	case "PONG":
+		return false
+	case "QUIT":
+		c.sendError("Bye")
+		return true
+	case "NICK":
+		c.handleNick(msg)
+		return false
+	case "USER":
+		c.handleUser(msg)
+		return false
+	}
+
+	if !c.registered {
+		c.sendNumeric(errNotRegistered, "You have not registered")
+		return false
+	}
+
+	switch msg.Command {
+	case "JOIN":
+		c.handleJoin(msg)
+	case "PART":
+		c.handlePart(msg)
+	case "PRIVMSG", "NOTICE":
+		c.handlePrivmsg(msg)
+	case "NAMES":
+		c.handleNames(msg)
+	case "TOPIC":
+		c.handleTopic(msg)
+	case "MOTD":
+		c.sendMotd()
+	default:
+		c.sendNumeric(errUnknownCommand, msg.Command, "Unknown command")
+	}
+	return false
+}
+
+func (c *ircClient) handleNick(msg ircMessage) {
+	if len(msg.Params) < 1 {
+		c.sendNumeric(errNoNicknameGiven, "No nickname given")
+		return
+	}
+	nick := msg.Params[0]
+	if c.registered {
+		// Renaming would have to be mirrored into every room's presence
+		c.sendNumeric(errErroneusNickname, nick, "Nickname changes are not supported")
+		return
+	}
+	if !isValidNick(nick) {
+		c.sendNumeric(errErroneusNickname, nick, "Erroneous nickname")
+		return
+	}
+	if !c.server.claimNick(c, nick) {
+		c.sendNumeric(errNicknameInUse, nick, "Nickname is already in use")
+		return
+	}
+	c.nick = nick
+	c.completeRegistration()
+}
+
+func (c *ircClient) handleUser(msg ircMessage) {
+	if c.registered {
+		c.sendNumeric(errAlreadyRegistred, "You may not reregister")
+		return
+	}
+	if len(msg.Params) < 4 {
+		c.sendNumeric(errNeedMoreParams, "USER", "Not enough parameters")
+		return
+	}
+	c.user = msg.Params[0]
+	c.completeRegistration()
+}
+
+func (c *ircClient) completeRegistration() {
+	if c.registered || c.nick == "" || c.user == "" {
+		return
+	}
+	c.registered = true
+
+	c.sendNumeric(rplWelcome, fmt.Sprintf("Welcome to %s, %s", ircServerName, c.prefix()))
+	c.sendNumeric(rplYourHost, fmt.Sprintf("Your host is %s, rooms are available as #<room id>", ircServerName))
+	c.sendNumeric(rplCreated, "This server bridges SSH chat rooms")
+	c.sendNumeric(rplMyInfo, ircServerName, "ssh-chat", "i", "t")
+	c.sendMotd()
+}
+
+func (c *ircClient) sendMotd() {
+	motd := serverState.Config().MOTD
+	if motd == "" {
+		c.sendNumeric(errNoMotd, "MOTD File is missing")
+		return
+	}
+	c.sendNumeric(rplMotdStart, fmt.Sprintf("- %s Message of the day -", ircServerName))
+	for _, line := range strings.Split(motd, "\n") {
+		c.sendNumeric(rplMotd, "- "+line)
+	}
+	c.sendNumeric(rplEndOfMotd, "End of MOTD command")
+}
+
+func (c *ircClient) handleJoin(msg ircMessage) {
+	if len(msg.Params) < 1 {
+		c.sendNumeric(errNeedMoreParams, "JOIN", "Not enough parameters")
+		return
+	}
+
+	for _, channel := range strings.Split(msg.Params[0], ",") {
+		roomId := roomIdFromChannel(channel)
+		if _, joined := c.channels[roomId]; joined {
+			continue
+		}
+		if serverState.shuttingDown.Load() {
+			c.sendNumeric(errNoSuchChannel, channel, "Server is shutting down")
+			continue
+		}
+		serverRoom, err := findRoom(roomId)
+		if err != nil {
+			c.sendNumeric(errNoSuchChannel, channel, "No such channel")
+			continue
+		}
+
+		serverRoom.AddActiveUser(c.nick)
+		auditRoomPresence(audit.TypeRoomJoin, c.sessionId, c.nick, c.conn.RemoteAddr().String(), roomId)
+		events := serverRoom.Subscribe()
+		c.channels[roomId] = events
+		go c.relay(events)
+
+		c.sendf(":%s JOIN %s", c.prefix(), channelFromRoomId(roomId))
+		c.sendTopic(serverRoom)
+		c.sendNames(serverRoom)
+	}
+}
+
+func (c *ircClient) handlePart(msg ircMessage) {
+	if len(msg.Params) < 1 {
+		c.sendNumeric(errNeedMoreParams, "PART", "Not enough parameters")
+		return
+	}
+
+	for _, channel := range strings.Split(msg.Params[0], ",") {
+		roomId := roomIdFromChannel(channel)
+		if _, joined := c.channels[roomId]; !joined {
+			c.sendNumeric(errNotOnChannel, channel, "You're not on that channel")
+			continue
+		}
+		c.part(roomId)
+		c.sendf(":%s PART %s", c.prefix(), channelFromRoomId(roomId))
+	}
+}
+
+func (c *ircClient) part(roomId string) {
+	events := c.channels[roomId]
+	delete(c.channels, roomId)
+
+	serverRoom := serverState.Room(roomId)
+	if serverRoom == nil {
+		return
+	}
+	serverRoom.Unsubscribe(events)
+	serverRoom.RemoveActiveUser(c.nick)
+	auditRoomPresence(audit.TypeRoomLeave, c.sessionId, c.nick, c.conn.RemoteAddr().String(), roomId)
+}
+
+func (c *ircClient) handlePrivmsg(msg ircMessage) {
+	if len(msg.Params) < 2 {
+		c.sendNumeric(errNeedMoreParams, msg.Command, "Not enough parameters")
+		return
+	}
+
+	target, text := msg.Params[0], msg.Params[1]
+	if !strings.HasPrefix(target, "#") {
+		c.sendNumeric(errNoSuchNick, target, "Direct messages are not supported")
+		return
+	}
+	roomId := roomIdFromChannel(target)
+	serverRoom, err := findRoom(roomId)
+	if err != nil {
+		c.sendNumeric(errNoSuchChannel, target, "No such channel")
+		return
+	}
+	if _, joined := c.channels[roomId]; !joined {
+		c.sendNumeric(errCannotSendToChan, target, "Cannot send to channel")
+		return
+	}
+
+	// CTCP ACTION, i.e. /me
+	if action, ok := strings.CutPrefix(text, "\x01ACTION "); ok {
+		text = "* " + c.nick + " " + strings.TrimSuffix(action, "\x01")
+	}
+
+	_, err = postMessage(serverRoom, model.Message{
+		Username:    c.nick,
+		Text:        text,
+		Timestamp:   time.Now(),
+		Integration: ircIntegration,
+	})
+	if err != nil {
+		c.sendf(":%s NOTICE %s :%s", ircServerName, c.nick, err.Error())
+	}
+}
+
+func (c *ircClient) handleNames(msg ircMessage) {
+	if len(msg.Params) < 1 {
+		c.sendNumeric(rplEndOfNames, "*", "End of /NAMES list")
+		return
+	}
+	for _, channel := range strings.Split(msg.Params[0], ",") {
+		serverRoom, err := findRoom(roomIdFromChannel(channel))
+		if err != nil {
+			c.sendNumeric(rplEndOfNames, channel, "End of /NAMES list")
+			continue
+		}
+		c.sendNames(serverRoom)
+	}
+}
+
+func (c *ircClient) handleTopic(msg ircMessage) {
+	if len(msg.Params) < 1 {
+		c.sendNumeric(errNeedMoreParams, "TOPIC", "Not enough parameters")
+		return
+	}
+	channel := msg.Params[0]
+	serverRoom, err := findRoom(roomIdFromChannel(channel))
+	if err != nil {
+		c.sendNumeric(errNoSuchChannel, channel, "No such channel")
+		return
+	}
+
+	if len(msg.Params) < 2 {
+		c.sendTopic(serverRoom)
+		return
+	}
+	if _, joined := c.channels[serverRoom.roomId]; !joined {
+		c.sendNumeric(errNotOnChannel, channel, "You're not on that channel")
+		return
+	}
+	// Everyone gets the change, this client included, through the relay
+	serverRoom.SetTopic(c.nick, msg.Params[1])
+	auditLog.Log(audit.Event{
+		Type:       audit.TypeModeration,
+		Result:     audit.ResultOk,
+		SessionId:  c.sessionId,
+		User:       c.nick,
+		RemoteAddr: c.conn.RemoteAddr().String(),
+		Room:       serverRoom.roomId,
+		Action:     "topic",
+		Details:    map[string]string{"topic": msg.Params[1]},
+	})
+}
+
+func (c *ircClient) sendTopic(serverRoom *room) {
+	channel := channelFromRoomId(serverRoom.roomId)
+	if topic := serverRoom.Topic(); topic != "" {
+		c.sendNumeric(rplTopic, channel, topic)
+	} else {
+		c.sendNumeric(rplNoTopic, channel, "No topic is set")
+	}
+}
+
+func (c *ircClient) sendNames(serverRoom *room) {
+	activeUsers := serverRoom.ActiveUsers()
+
+	slices.Sort(activeUsers)
+	activeUsers = slices.Compact(activeUsers)
+
+	channel := channelFromRoomId(serverRoom.roomId)
+	for chunk := range slices.Chunk(activeUsers, 20) {
+		c.sendNumeric(rplNameReply, "=", channel, strings.Join(chunk, " "))
+	}
+	c.sendNumeric(rplEndOfNames, channel, "End of /NAMES list")
+}
+
+// Mirrors room events into the IRC connection until the room unsubscribes us
+func (c *ircClient) relay(events chan model.Event) {
+	for event := range events {
+		channel := channelFromRoomId(event.RoomId)
+		source := fmt.Sprintf("%s!%s@%s", ircNick(event.Username), ircNick(event.Username), ircServerName)
+
+		switch event.Kind {
+		case model.EventMessage:
+			// IRC clients echo their own messages locally, SSH users with the
+			// same name still get through
+			if event.Username == c.nick && event.Message.Integration == ircIntegration {
+				continue
+			}
+			if event.Message.Integration != "" {
+				source = fmt.Sprintf("%s!%s@%s", ircNick(event.Username), ircNick(event.Message.Integration), ircServerName)
+			}
+			for _, line := range strings.Split(event.Message.PlainText(), "\n") {
+				if line == "" {
+					continue
+				}
+				c.sendf(":%s PRIVMSG %s :%s", source, channel, line)
+			}
+		case model.EventJoin:
+			if event.Username != c.nick {
+				c.sendf(":%s JOIN %s", source, channel)
+			}
+		case model.EventLeave:
+			if event.Username != c.nick {
+				c.sendf(":%s PART %s", source, channel)
+			}
+		case model.EventTopic:
+			c.sendf(":%s TOPIC %s :%s", source, channel, event.Topic)
+		}
+	}
+}
+
+func newIRCSessionId() string {
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+	return "irc-" + hex.EncodeToString(id)
+}
+
+func isValidNick(nick string) bool {
+	if nick == "" || len(nick) > 32 || strings.HasPrefix(nick, "#") {
+		return false
+	}
+	return !strings.ContainsAny(nick, " ,*?!@:\x00\r\n")
+}
+
+// SSH user names may contain characters IRC uses as separators
+func ircNick(name string) string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(" ,*?!@:", r) {
+			return '_'
+		}
+		return r
+	}, name)
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/NaiKiDEV/ssh-chat/internal/config"
+	"github.com/NaiKiDEV/ssh-chat/internal/keymap"
+	"github.com/charmbracelet/log"
+	"github.com/charmbracelet/ssh"
+)
+
+// Client side env var overriding key bindings for one session, e.g.
+// `ssh -o SetEnv="SSH_CHAT_KEYS=chat.scroll_up=k;chat.scroll_down=j"`
+const keysEnvVar = "SSH_CHAT_KEYS"
+
+// Operator bindings from the config with the ones of the session on top,
+// mistakes in the session env var are reported back to the user
+func sessionKeyMap(s ssh.Session) (*keymap.KeyMap, error) {
+	keyMap := keymap.Default()
+	// Unknown names were already reported when the config was loaded
+	_ = keyMap.Override(serverState.Config().KeyBindings)
+
+	for _, env := range s.Environ() {
+		value, ok := strings.CutPrefix(env, keysEnvVar+"=")
+		if !ok {
+			continue
+		}
+		overrides, err := keymap.ParseOverrides(value)
+		if err != nil {
+			return keyMap, fmt.Errorf("%s: %w", keysEnvVar, err)
+		}
+		if err := keyMap.Override(overrides); err != nil {
+			return keyMap, fmt.Errorf("%s: %w", keysEnvVar, err)
+		}
+	}
+	return keyMap, nil
+}
+
+func warnInvalidKeyBindings(cfg *config.Config) {
+	if err := keymap.Default().Override(cfg.KeyBindings); err != nil {
+		log.Warn("Ignoring unknown key bindings", "error", err)
+	}
+}
@@ -3,20 +3,34 @@ package main
 import (
 	"context"
 	"errors"
+	"flag"
+	"fmt"
+	"math"
 	"net"
+	"net/http"
 	"os"
 	"os/signal"
 	"slices"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
+	"github.com/NaiKiDEV/ssh-chat/internal/attachment"
+	"github.com/NaiKiDEV/ssh-chat/internal/audit"
+	"github.com/NaiKiDEV/ssh-chat/internal/config"
+	"github.com/NaiKiDEV/ssh-chat/internal/emoji"
+	"github.com/NaiKiDEV/ssh-chat/internal/keymap"
 	"github.com/NaiKiDEV/ssh-chat/internal/model"
+	"github.com/NaiKiDEV/ssh-chat/internal/plugin"
 	"github.com/NaiKiDEV/ssh-chat/internal/styles"
 	"github.com/NaiKiDEV/ssh-chat/internal/terminal"
+	"github.com/NaiKiDEV/ssh-chat/internal/webhook"
 	"github.com/NaiKiDEV/ssh-chat/views/chat"
 	"github.com/NaiKiDEV/ssh-chat/views/login"
+	"github.com/charmbracelet/bubbles/key"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/charmbracelet/log"
@@ -37,41 +51,84 @@ const (
 	VIEW_CHAT  = "chat"
 )
 
-type room struct {
-	mutex sync.Mutex
+// Client side env var that opts a single session into the accessible mode,
+// e.g. `ssh -o SetEnv=SSH_CHAT_ACCESSIBLE=1`, on the server it sets the default
+const accessibleEnvVar = "SSH_CHAT_ACCESSIBLE"
 
-	roomId      string
-	messages    []model.Message
-	activeUsers []string
+// Client side env var forcing clickable links on or off, terminals can't be
+// asked whether they support OSC 8 so by default it is guessed from TERM
+const hyperlinksEnvVar = "SSH_CHAT_HYPERLINKS"
+
+// TERM values of terminals known to support OSC 8 hyperlinks
+var hyperlinkTerms = []string{"kitty", "wezterm", "ghostty", "foot", "alacritty", "contour"}
+
+var (
+	configPath    = flag.String("config", "", "path to the JSON config file")
+	httpAddr      = flag.String("http", "", "address of the optional HTTP API listener, e.g. localhost:8080")
+	ircAddr       = flag.String("irc", "", "address of the optional IRC gateway, e.g. localhost:6667")
+	auditLogPath  = flag.String("audit-log", "", "path of the JSON lines audit log, rotated at 10MB")
+	metricsAddr   = flag.String("metrics", "", "address of the optional Prometheus metrics listener, e.g. localhost:9090")
+	shutdownGrace = flag.Duration("shutdown-grace", 10*time.Second, "how long sessions are warned before the server stops")
+)
+
+var accessibleByDefault = flag.Bool("accessible", envBool(os.Getenv(accessibleEnvVar)), "render a plain text transcript instead of the TUI for every session")
+
+type ServerState struct {
+	// Guards rooms and config which change on config reloads
+	mutex   sync.RWMutex
+	rooms   map[string]*room
+	config  *config.Config
+	plugins *plugin.Pipeline
+	irc     *ircServer
+	notices noticeHub
+	users   userRegistry
+	// No new room joins once set
+	shuttingDown atomic.Bool
 }
 
-func (r *room) AddMessage(msg model.Message) {
-	r.mutex.Lock()
-	defer r.mutex.Unlock()
-	r.messages = append(r.messages, msg)
+func (s *ServerState) Room(roomId string) *room {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.rooms[roomId]
 }
 
-func (r *room) RemoveActiveUser(userName string) {
-	r.mutex.Lock()
-	defer r.mutex.Unlock()
+// Rooms returns all rooms ordered by id
+func (s *ServerState) Rooms() []*room {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
 
-	activeUsers := r.activeUsers
-	userIdx := slices.Index(activeUsers, userName)
-	if userIdx != -1 {
-		r.activeUsers[userIdx] = activeUsers[len(activeUsers)-1]
-		r.activeUsers = activeUsers[:len(activeUsers)-1]
+	rooms := make([]*room, 0, len(s.rooms))
+	for _, serverRoom := range s.rooms {
+		rooms = append(rooms, serverRoom)
 	}
+	slices.SortFunc(rooms, func(a, b *room) int {
+		return strings.Compare(a.roomId, b.roomId)
+	})
+	return rooms
 }
 
-func (r *room) AddActiveUser(userName string) {
-	r.mutex.Lock()
-	defer r.mutex.Unlock()
+// AddRoom creates the room unless it exists already, reports whether it did
+func (s *ServerState) AddRoom(roomId string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
 
-	r.activeUsers = append(r.activeUsers, userName)
+	if _, ok := s.rooms[roomId]; ok {
+		return false
+	}
+	s.rooms[roomId] = newRoom(roomId)
+	return true
 }
 
-type ServerState struct {
-	rooms map[string]*room
+func (s *ServerState) Config() *config.Config {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.config
+}
+
+func (s *ServerState) SetConfig(cfg *config.Config) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.config = cfg
 }
 
 type user struct {
@@ -84,46 +141,96 @@ type clientState struct {
 	renderer      *lipgloss.Renderer
 	loginState    login.LoginState
 	chatState     chat.ChatState
+	keyMap        *keymap.KeyMap
 	user          *user
 	activeView    string
 	roomId        string
+	roomEvents    chan model.Event
+	session       *clientSession
+	// Linear transcript without alt-screen, colors or layout
+	accessible bool
+	// Set once the server announced it is going away
+	shutdownDeadline time.Time
 }
 
 // Global var :(
 var serverState ServerState
 
 func main() {
-	s, err := wish.NewServer(
+	flag.Parse()
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatal("Could not load config", "error", err)
+	}
+	warnInvalidKeyBindings(cfg)
+
+	if *auditLogPath != "" {
+		auditLog, err = audit.Open(audit.Options{
+			Path:       *auditLogPath,
+			MaxSize:    10 * 1024 * 1024,
+			MaxBackups: 5,
+		})
+		if err != nil {
+			log.Fatal("Could not open audit log", "error", err)
+		}
+		defer auditLog.Close()
+	}
+
+	if cfg.AttachmentsPath != "" {
+		attachments, err = attachment.NewStore(cfg.AttachmentsPath)
+		if err != nil {
+			log.Fatal("Could not open attachments", "error", err)
+		}
+	}
+
+	serverOptions := []ssh.Option{
 		wish.WithAddress(net.JoinHostPort(host, port)),
 		wish.WithHostKeyPath(".ssh/id_ed25519"),
 		wish.WithMiddleware(
+			sessionCleanupMiddleware(),
 			bubbletea.Middleware(teaHandler),
 			activeterm.Middleware(),
+			execMiddleware(),
+			transcriptSCPMiddleware(),
+			metricsMiddleware(),
 			logging.Middleware(),
 		),
-	)
+		wish.WithSubsystem("sftp", transcriptSFTPHandler),
+		withHandshakeFailureMetrics(),
+	}
+	s, err := wish.NewServer(append(serverOptions, withAuditedAuth()...)...)
 	if err != nil {
 		log.Error("Could not start server", "error", err)
 	}
 
+	// Set up before SIGHUP reloads can touch them
+	serverState.rooms = map[string]*room{}
+	serverState.config = cfg
+	for _, roomId := range append([]string{"secret", "public"}, cfg.Rooms...) {
+		createRoom(roomId, "startup")
+	}
+
 	done := make(chan os.Signal, 1)
 	signal.Notify(done, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
+
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	go func() {
+		for range reload {
+			reloadConfig()
+		}
+	}()
 	log.Info("Starting SSH server", "host", host, "port", port)
 
-	serverState = ServerState{
-		rooms: map[string]*room{
-			"secret": {
-				roomId:      "secret",
-				messages:    []model.Message{},
-				activeUsers: []string{},
-			},
-			"public": {
-				roomId:      "public",
-				messages:    []model.Message{},
-				activeUsers: []string{},
-			},
-		},
-	}
+	// Sessions post through the plugins as soon as the listener is up
+	serverState.plugins = startPlugins(cfg.Plugins)
+	defer serverState.plugins.Close()
+
+	webhookOptions := webhook.DefaultOptions()
+	webhookOptions.DeadLetterPath = cfg.WebhookDeadLetterPath
+	webhooks := webhook.NewDispatcher(webhookOptions)
+	startWebhooks(webhooks, cfg.Webhooks)
 
 	go func() {
 		if err = s.ListenAndServe(); err != nil && !errors.Is(err, ssh.ErrServerClosed) {
@@ -132,13 +239,53 @@ func main() {
 		}
 	}()
 
-	<-done
-	log.Info("Stopping SSH server")
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer func() { cancel() }()
-	if err := s.Shutdown(ctx); err != nil && !errors.Is(err, ssh.ErrServerClosed) {
-		log.Error("Could not stop server", "error", err)
+	var httpServer *http.Server
+	if *httpAddr != "" {
+		// Event streams never finish on their own, end them when shutting down
+		streamsCtx, cancelStreams := context.WithCancel(context.Background())
+		httpServer = &http.Server{
+			Addr:              *httpAddr,
+			Handler:           newAPIHandler(),
+			ReadHeaderTimeout: 10 * time.Second,
+			BaseContext:       func(net.Listener) context.Context { return streamsCtx },
+		}
+		httpServer.RegisterOnShutdown(cancelStreams)
+		log.Info("Starting HTTP API", "address", *httpAddr)
+		go func() {
+			if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Error("Could not start HTTP API", "error", err)
+				done <- nil
+			}
+		}()
+	}
+
+	var metricsServer *http.Server
+	if *metricsAddr != "" {
+		metricsMux := http.NewServeMux()
+		metricsMux.Handle("GET /metrics", metricsRegistry.Handler())
+		metricsServer = &http.Server{
+			Addr:              *metricsAddr,
+			Handler:           metricsMux,
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		log.Info("Starting metrics listener", "address", *metricsAddr)
+		go func() {
+			if err := metricsServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Error("Could not start metrics listener", "error", err)
+			}
+		}()
+	}
+
+	if *ircAddr != "" {
+		if serverState.irc, err = startIRCServer(*ircAddr); err != nil {
+			log.Error("Could not start IRC gateway", "error", err)
+		} else {
+			log.Info("Starting IRC gateway", "address", *ircAddr)
+		}
 	}
+
+	<-done
+	shutdownGracefully(s, httpServer, metricsServer, webhooks)
 }
 
 func teaHandler(s ssh.Session) (tea.Model, []tea.ProgramOption) {
@@ -146,8 +293,15 @@ func teaHandler(s ssh.Session) (tea.Model, []tea.ProgramOption) {
 
 	userName := s.User()
 
+	session, err := newClientSession(s)
+	if err != nil {
+		wish.Fatalln(s, err)
+		return nil, nil
+	}
+
 	renderer := bubbletea.MakeRenderer(s)
 	cStyles := styles.NewClientStyles(renderer)
+	cStyles.Hyperlinks = supportsHyperlinks(s, pty)
 
 	theme := "light"
 	if renderer.HasDarkBackground() {
@@ -160,8 +314,21 @@ func teaHandler(s ssh.Session) (tea.Model, []tea.ProgramOption) {
 		Theme:  theme,
 	}
 
-	loginState := login.NewLoginState(userName)
-	chatState := chat.NewChatState(userName, tState, cStyles)
+	accessible := *accessibleByDefault || isAccessibleSession(s, pty)
+
+	keyMap, keysErr := sessionKeyMap(s)
+
+	loginState := login.NewLoginState(userName, tState, cStyles).
+		SetAccessible(accessible).
+		SetMOTD(serverState.Config().MOTD).
+		SetKeyMap(keyMap)
+	if keysErr != nil {
+		loginState = loginState.SetNotice(keysErr.Error(), true)
+	}
+	chatState := chat.NewChatState(userName, tState, cStyles).
+		SetAccessible(accessible).
+		SetComposerHeight(serverState.Config().ComposerHeight).
+		SetKeyMap(keyMap)
 
 	m := clientState{
 		terminalState: tState,
@@ -169,17 +336,121 @@ func teaHandler(s ssh.Session) (tea.Model, []tea.ProgramOption) {
 		renderer:      renderer,
 		loginState:    loginState,
 		chatState:     chatState,
+		keyMap:        keyMap,
 		activeView:    VIEW_LOGIN,
+		accessible:    accessible,
+		session:       session,
 		// This should become available after login
 		user: &user{
 			displayName: userName,
 		},
 	}
-	return m, []tea.ProgramOption{tea.WithAltScreen(), tea.WithMouseCellMotion()}
+	// Signals are meant for the server, sessions wind down through notices
+	if accessible {
+		return m, []tea.ProgramOption{tea.WithoutSignalHandler()}
+	}
+	return m, []tea.ProgramOption{tea.WithoutSignalHandler(), tea.WithAltScreen(), tea.WithMouseCellMotion()}
+}
+
+// Screen readers and dumb terminals can't make sense of the alt-screen layout
+func isAccessibleSession(s ssh.Session, pty ssh.Pty) bool {
+	if pty.Term == "dumb" {
+		return true
+	}
+	for _, env := range s.Environ() {
+		if value, ok := strings.CutPrefix(env, accessibleEnvVar+"="); ok {
+			return envBool(value)
+		}
+	}
+	return false
+}
+
+func supportsHyperlinks(s ssh.Session, pty ssh.Pty) bool {
+	for _, env := range s.Environ() {
+		if value, ok := strings.CutPrefix(env, hyperlinksEnvVar+"="); ok {
+			return envBool(value)
+		}
+	}
+	for _, term := range hyperlinkTerms {
+		if strings.Contains(pty.Term, term) {
+			return true
+		}
+	}
+	return false
+}
+
+func envBool(value string) bool {
+	enabled, err := strconv.ParseBool(value)
+	return err == nil && enabled
+}
+
+// Sessions that drop without leaving are cleaned up by sessionCleanupMiddleware
+func (m clientState) leaveRoom() clientState {
+	m.session.LeaveRoom()
+	m.roomId = ""
+	m.roomEvents = nil
+	return m
 }
 
 func (m clientState) Init() tea.Cmd {
-	return nil
+	return waitForServerNotice(m.session.notices)
+}
+
+func (m clientState) joinRoom(serverRoom *room) (clientState, tea.Cmd) {
+	m.activeView = VIEW_CHAT
+	m.chatState = m.chatState.SetRoom(serverRoom.roomId, serverRoom.Messages()).
+		SetTopic(serverRoom.Topic()).
+		SetRooms(roomSource{}.RoomIds()).
+		SetCommands(m.session.Commands()).
+		SetDraft(userInput.Draft(m.session.Identity(), serverRoom.roomId)).
+		SetHistory(userInput.History(m.session.Identity()))
+
+	m.roomId = serverRoom.roomId
+	m.roomEvents = m.session.JoinRoom(serverRoom)
+
+	motd := serverState.Config().MOTD
+	if m.shutdownDeadline.IsZero() {
+		m.chatState = m.chatState.SetNotice(motd, false)
+	}
+
+	cmd := waitForRoomEvent(m.roomEvents)
+	if m.accessible {
+		intro := chat.FormatPlainRoomIntro(serverRoom.roomId, serverRoom.Messages(), serverRoom.ActiveUsers())
+		if motd != "" {
+			intro = "Message of the day: " + motd + "\n" + intro
+		}
+		cmd = tea.Sequence(tea.Println(intro), cmd)
+	}
+	return m, cmd
+}
+
+// Leaves the current room for roomId, the notice tells when that is not possible
+func (m clientState) switchRoom(roomId string) (clientState, tea.Cmd, bool) {
+	serverRoom := serverState.Room(roomId)
+	if serverRoom == nil || serverState.shuttingDown.Load() {
+		m.chatState = m.chatState.SetNotice("could not switch to room "+roomId, true)
+		return m, nil, false
+	}
+	m = m.leaveRoom()
+	m, cmd := m.joinRoom(serverRoom)
+	return m, cmd, true
+}
+
+// Errors of chat input go to the notice, or the transcript in accessible mode
+func (m clientState) showInputError(err error) (clientState, tea.Cmd) {
+	if m.accessible {
+		return m, tea.Println("Error: " + err.Error())
+	}
+	m.chatState = m.chatState.SetNotice(err.Error(), true)
+	return m, nil
+}
+
+func (m clientState) showShutdownCountdown() clientState {
+	seconds := int(math.Ceil(time.Until(m.shutdownDeadline).Seconds()))
+	notice := fmt.Sprintf("Server shutting down in %ds", max(seconds, 0))
+	m.chatState = m.chatState.SetNotice(notice, true)
+	m.loginState = m.loginState.SetNotice(notice, true)
+	return m
 }
 
 func (m clientState) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -187,64 +458,140 @@ func (m clientState) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tea.WindowSizeMsg:
 		m.terminalState.Height = msg.Height
 		m.terminalState.Width = msg.Width
+		// Kept in size while on the login too, rooms are joined at any size
+		m.chatState = m.chatState.Resize(m.terminalState)
 
-		if m.activeView == VIEW_CHAT {
-			m.chatState = m.chatState.Resize(m.terminalState)
+	// Global input handling that takes priority over views
+	case tea.KeyMsg:
+		if key.Matches(msg, m.keyMap.Global.Quit) {
+			m = m.leaveRoom()
+			return m, tea.Quit
 		}
 
-	case tea.MouseMsg:
-		if m.activeView == VIEW_CHAT {
-			var cmd tea.Cmd
-			m.chatState, cmd = m.chatState.Update(msg)
-			return m, cmd
+	case serverNoticeMsg:
+		cmd := waitForServerNotice(m.session.notices)
+		switch msg.Notice.Kind {
+		case noticeShutdown:
+			m.shutdownDeadline = msg.Notice.Deadline
+			m = m.showShutdownCountdown()
+			if m.accessible {
+				cmd = tea.Batch(cmd, tea.Println(msg.Notice.Text))
+			}
+			return m, tea.Batch(cmd, shutdownTick())
+		case noticeAnnouncement:
+			text := formatAnnouncement(msg.Notice)
+			if m.accessible {
+				return m, tea.Batch(cmd, tea.Println(text))
+			}
+			// The countdown is more important than anything announced
+			if m.shutdownDeadline.IsZero() {
+				m.chatState = m.chatState.SetNotice(text, false)
+				m.loginState = m.loginState.SetNotice(text, false)
+			}
 		}
+		return m, cmd
 
-	// Global input handling that takes priority over views
-	case tea.KeyMsg:
-		switch key := msg.Type; key {
-		case tea.KeyCtrlC:
-			serverRoom := serverState.rooms[m.roomId]
-			if serverRoom == nil {
-				return m, tea.Quit
-			}
+	case shutdownTickMsg:
+		if time.Now().After(m.shutdownDeadline) {
+			m = m.leaveRoom()
+			return m, tea.Quit
+		}
+		m = m.showShutdownCountdown()
+		return m, shutdownTick()
 
-			// TODO: Should also handle non-graceful logouts
-			serverRoom.RemoveActiveUser(m.user.displayName)
+	case roomEventMsg:
+		if msg.Event.RoomId != m.roomId {
+			return m, nil
+		}
+		cmd := waitForRoomEvent(m.roomEvents)
+		if m.accessible {
+			cmd = tea.Sequence(tea.Println(chat.FormatPlainEvent(msg.Event)), cmd)
+		}
+		if msg.Event.Kind == model.EventTopic {
+			m.chatState = m.chatState.SetTopic(msg.Event.Topic)
+		}
+		if serverRoom := serverState.Room(m.roomId); serverRoom != nil {
+			m.chatState = m.chatState.SetChatState(serverRoom.Messages(), serverRoom.ActiveUsers())
+		}
+		return m, cmd
 
-			return m, tea.Quit
+	case login.AccessibleModeToggledMsg:
+		m.accessible = msg.Enabled
+		m.chatState = m.chatState.SetAccessible(msg.Enabled)
+		if msg.Enabled {
+			return m, tea.Batch(tea.ExitAltScreen, tea.DisableMouse)
 		}
+		return m, tea.Batch(tea.EnterAltScreen, tea.EnableMouseCellMotion)
 
 	case chat.MessageSentMsg:
-		serverRoom := serverState.rooms[m.roomId]
-		if serverRoom != nil {
-			serverRoom.AddMessage(model.Message{
+		serverRoom := serverState.Room(m.roomId)
+		if serverRoom == nil {
+			return m, nil
+		}
+		m.chatState = m.chatState.SetNotice("", false)
+		userInput.AddHistory(m.session.Identity(), msg.Message)
+		m.chatState = m.chatState.SetHistory(userInput.History(m.session.Identity()))
+		var cmd tea.Cmd
+		var err error
+		name, _, isCommand := plugin.ParseCommand(msg.Message)
+		switch {
+		case isCommand && name == announceCommand:
+			err = m.session.Announce(strings.TrimPrefix(msg.Message, "/"+announceCommand))
+		case isCommand && name == searchCommand:
+			m, cmd, err = m.search(strings.TrimPrefix(msg.Message, "/"+searchCommand))
+		default:
+			cmd = postChatInputCmd(m.session, serverRoom, model.Message{
 				Username:  m.user.displayName,
-				Text:      msg.Message,
+				Text:      emoji.Expand(msg.Message),
 				Timestamp: time.Now(),
 			})
 		}
+		if err != nil {
+			return m.showInputError(err)
+		}
+		return m, cmd
+
+	case chatInputPostedMsg:
+		if msg.Err != nil {
+			return m.showInputError(msg.Err)
+		}
 		return m, nil
 
+	case chat.SearchResultSelectedMsg:
+		var cmd tea.Cmd
+		if msg.Result.RoomId != m.roomId {
+			var switched bool
+			if m, cmd, switched = m.switchRoom(msg.Result.RoomId); !switched {
+				return m, nil
+			}
+		}
+		m.chatState = m.chatState.JumpToMessage(msg.Result.Position, msg.Terms)
+		return m, cmd
+
+	case chat.RoomSelectedMsg:
+		var cmd tea.Cmd
+		m, cmd, _ = m.switchRoom(msg.RoomId)
+		return m, cmd
+
 	case login.RoomJoinRequestedMsg:
 		roomId := msg.RoomId
-		serverRoom := serverState.rooms[roomId]
+		if serverState.shuttingDown.Load() {
+			m.loginState = m.loginState.SetFormError("server is shutting down")
+			return m, nil
+		}
+		serverRoom := serverState.Room(roomId)
 		if serverRoom != nil {
-			m.activeView = VIEW_CHAT
-			m.chatState = m.chatState.SetRoom(serverRoom.roomId, serverRoom.messages)
-
-			m.roomId = roomId
-			serverRoom.AddActiveUser(m.user.displayName)
+			return m.joinRoom(serverRoom)
 		} else {
 			m.loginState = m.loginState.SetFormError("room not found")
 			return m, nil
 		}
 
 	case chat.LeaveChatMsg:
-		serverRoom := serverState.rooms[m.roomId]
-		if serverRoom == nil {
+		if serverState.Room(m.roomId) == nil {
 			return m, tea.Quit
 		}
-		serverRoom.RemoveActiveUser(m.user.displayName)
+		m = m.leaveRoom()
 		m.activeView = VIEW_LOGIN
 		m.loginState = m.loginState.Reset()
 		return m, nil
@@ -261,13 +608,15 @@ func (m clientState) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		var cmd tea.Cmd
 
 		// TODO: maybe there is a better way?
-		serverRoom := serverState.rooms[m.roomId]
+		serverRoom := serverState.Room(m.roomId)
 		if serverRoom == nil {
 			return m, nil
 		}
 
-		m.chatState = m.chatState.SetChatState(serverRoom.messages, serverRoom.activeUsers)
+		m.chatState = m.chatState.SetChatState(serverRoom.Messages(), serverRoom.ActiveUsers())
 		m.chatState, cmd = m.chatState.Update(msg)
+		// Kept on every change, sessions can drop at any time
+		userInput.SetDraft(m.session.Identity(), m.roomId, m.chatState.Draft())
 
 		return m, cmd
 	}
@@ -276,20 +625,33 @@ func (m clientState) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 }
 
 func (m clientState) View() string {
+	start := time.Now()
+	defer func() { renderSeconds.Observe(time.Since(start).Seconds()) }()
+
 	var view strings.Builder
 
+	if m.accessible {
+		switch m.activeView {
+		case VIEW_LOGIN:
+			view.WriteString(m.loginState.RenderPlain())
+		case VIEW_CHAT:
+			view.WriteString(m.chatState.RenderPlain())
+		}
+		return view.String()
+	}
+
 	if m.activeView == VIEW_LOGIN {
 		view.WriteString(m.loginState.Render(m.terminalState, m.clientStyles))
 	}
 
 	if m.activeView == VIEW_CHAT {
-		serverRoom := serverState.rooms[m.roomId]
+		serverRoom := serverState.Room(m.roomId)
 
 		var activeUsers []string
 		var messages []model.Message
 		if serverRoom != nil {
-			activeUsers = serverRoom.activeUsers
-			messages = serverRoom.messages
+			activeUsers = serverRoom.ActiveUsers()
+			messages = serverRoom.Messages()
 		}
 
 		view.WriteString(m.chatState.Render(m.terminalState, messages, activeUsers))
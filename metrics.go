@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net"
+
+	"github.com/NaiKiDEV/ssh-chat/internal/metrics"
+	"github.com/charmbracelet/log"
+	"github.com/charmbracelet/ssh"
+	"github.com/charmbracelet/wish"
+)
+
+var (
+	metricsRegistry = metrics.NewRegistry()
+
+	sessionsGauge = metricsRegistry.NewGauge(
+		"ssh_chat_sessions", "Connected SSH sessions by kind (interactive or exec).", "kind")
+	messagesCounter = metricsRegistry.NewCounter(
+		"ssh_chat_messages_total", "Messages posted per room.", "room")
+	joinsCounter = metricsRegistry.NewCounter(
+		"ssh_chat_room_joins_total", "Users joining a room.", "room")
+	leavesCounter = metricsRegistry.NewCounter(
+		"ssh_chat_room_leaves_total", "Users leaving a room.", "room")
+	renderSeconds = metricsRegistry.NewHistogram(
+		"ssh_chat_view_render_seconds", "Time spent rendering a client view.",
+		[]float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25})
+	handshakeFailuresCounter = metricsRegistry.NewCounter(
+		"ssh_chat_ssh_handshake_failures_total", "SSH connections that failed before a session started.")
+)
+
+func init() {
+	metricsRegistry.NewGaugeFunc("ssh_chat_room_sessions", "Users currently in a room.", "room", func() map[string]float64 {
+		values := map[string]float64{}
+		for _, serverRoom := range serverState.Rooms() {
+			serverRoom.mutex.Lock()
+			values[serverRoom.roomId] = float64(len(serverRoom.activeUsers))
+			serverRoom.mutex.Unlock()
+		}
+		return values
+	})
+}
+
+// Counts sessions for as long as their handlers run
+func metricsMiddleware() wish.Middleware {
+	return func(next ssh.Handler) ssh.Handler {
+		return func(s ssh.Session) {
+			kind := "interactive"
+			if len(s.Command()) > 0 {
+				kind = "exec"
+			}
+			sessionsGauge.Add(1, kind)
+			defer sessionsGauge.Add(-1, kind)
+			next(s)
+		}
+	}
+}
+
+func withHandshakeFailureMetrics() ssh.Option {
+	return func(s *ssh.Server) error {
+		s.ConnectionFailedCallback = func(conn net.Conn, err error) {
+			handshakeFailuresCounter.Inc()
+			log.Debug("SSH handshake failed", "remote", conn.RemoteAddr(), "error", err)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	"github.com/NaiKiDEV/ssh-chat/internal/config"
+	"github.com/NaiKiDEV/ssh-chat/internal/model"
+	"github.com/NaiKiDEV/ssh-chat/internal/plugin"
+	"github.com/charmbracelet/log"
+)
+
+// Lets plugins post on their own, their messages are marked as integrations
+type pluginHost struct{}
+
+func (pluginHost) PostMessage(pluginName string, roomId string, text string) error {
+	serverRoom, err := findRoom(roomId)
+	if err != nil {
+		return err
+	}
+	_, err = postMessage(serverRoom, model.Message{
+		Username:    pluginName,
+		Text:        text,
+		Timestamp:   time.Now(),
+		Integration: pluginName,
+	})
+	return err
+}
+
+func startPlugins(plugins []config.Plugin) *plugin.Pipeline {
+	pipeline := plugin.NewPipeline(pluginHost{})
+
+	if err := pipeline.Register(plugin.DiceRoller{}); err != nil {
+		log.Error("Could not register plugin", "error", err)
+	}
+
+	for _, pluginConfig := range plugins {
+		process, err := plugin.StartProcess(pluginConfig.Name, pluginConfig.Command)
+		if err != nil {
+			log.Error("Could not start plugin", "plugin", pluginConfig.Name, "error", err)
+			continue
+		}
+		if err := pipeline.Register(process); err != nil {
+			log.Error("Could not register plugin", "error", err)
+			_ = process.Close()
+			continue
+		}
+		log.Info("Plugin started", "plugin", pluginConfig.Name)
+	}
+
+	return pipeline
+}
+
+// postChatInput handles a line typed into the chat. Lines starting with a
+// slash are commands for plugins, a leading double slash posts the text with
+// a single slash and everything else is posted as is.
+func postChatInput(serverRoom *room, msg model.Message) error {
+	if text, ok := strings.CutPrefix(msg.Text, "//"); ok {
+		msg.Text = "/" + text
+	} else if name, args, ok := plugin.ParseCommand(msg.Text); ok {
+		return runPluginCommand(serverRoom, msg.Username, name, args)
+	}
+
+	_, err := postMessage(serverRoom, msg)
+	return err
+}
+
+// postMessage is the only way messages get into rooms. The message runs
+// through the plugin filters, gets stored and is handed to the observers.
+// Text is never read as a command, so the API, exec, IRC and webhooks post
+// lines starting with a slash verbatim.
+func postMessage(serverRoom *room, msg model.Message) (model.Message, error) {
+	msg, err := serverState.plugins.Filter(serverRoom.roomId, msg)
+	if err != nil {
+		return msg, err
+	}
+
+	serverRoom.AddMessage(msg)
+	serverState.plugins.Notify(serverRoom.roomId, msg)
+	return msg, nil
+}
+
+func runPluginCommand(serverRoom *room, userName string, name string, args []string) error {
+	pluginName, replies, err := serverState.plugins.RunCommand(plugin.CommandCall{
+		RoomId:   serverRoom.roomId,
+		Username: userName,
+		Name:     name,
+		Args:     args,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, reply := range replies {
+		if _, err := postMessage(serverRoom, model.Message{
+			Username:    pluginName,
+			Text:        reply,
+			Timestamp:   time.Now(),
+			Integration: pluginName,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
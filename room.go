@@ -0,0 +1,206 @@
+package main
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/NaiKiDEV/ssh-chat/internal/model"
+)
+
+// Size of the per-subscriber buffer, see Subscribe and SubscribeWithHistory
+// for what happens to subscribers falling behind
+const roomEventBufferSize = 64
+
+type room struct {
+	mutex sync.Mutex
+
+	roomId      string
+	topic       string
+	messages    []model.Message
+	activeUsers []string
+	subscribers map[chan model.Event]subscription
+	listeners   []func(model.Event)
+}
+
+type subscription struct {
+	// Close the channel after an EventDropped instead of silently dropping
+	// events once the buffer is full
+	closeWhenBehind bool
+}
+
+func newRoom(roomId string) *room {
+	return &room{
+		roomId:      roomId,
+		messages:    []model.Message{},
+		activeUsers: []string{},
+		subscribers: map[chan model.Event]subscription{},
+	}
+}
+
+func (r *room) AddMessage(msg model.Message) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.messages = append(r.messages, msg)
+	messagesCounter.Inc(r.roomId)
+	searchIndex.Add(r.roomId, len(r.messages)-1, msg)
+
+	r.publish(model.Event{
+		Kind:      model.EventMessage,
+		RoomId:    r.roomId,
+		Username:  msg.Username,
+		Message:   &msg,
+		Timestamp: msg.Timestamp,
+	})
+}
+
+func (r *room) RemoveActiveUser(userName string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	activeUsers := r.activeUsers
+	userIdx := slices.Index(activeUsers, userName)
+	if userIdx != -1 {
+		r.activeUsers[userIdx] = activeUsers[len(activeUsers)-1]
+		r.activeUsers = activeUsers[:len(activeUsers)-1]
+		leavesCounter.Inc(r.roomId)
+
+		r.publish(model.Event{
+			Kind:      model.EventLeave,
+			RoomId:    r.roomId,
+			Username:  userName,
+			Timestamp: time.Now(),
+		})
+	}
+}
+
+func (r *room) AddActiveUser(userName string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.activeUsers = append(r.activeUsers, userName)
+	joinsCounter.Inc(r.roomId)
+
+	r.publish(model.Event{
+		Kind:      model.EventJoin,
+		RoomId:    r.roomId,
+		Username:  userName,
+		Timestamp: time.Now(),
+	})
+}
+
+// Messages returns a copy of the history, safe to read while others post
+func (r *room) Messages() []model.Message {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return slices.Clone(r.messages)
+}
+
+// ActiveUsers returns a copy of the users currently in the room
+func (r *room) ActiveUsers() []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return slices.Clone(r.activeUsers)
+}
+
+func (r *room) Topic() string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.topic
+}
+
+func (r *room) SetTopic(userName string, topic string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.topic = topic
+	r.publish(model.Event{
+		Kind:      model.EventTopic,
+		RoomId:    r.roomId,
+		Username:  userName,
+		Topic:     topic,
+		Timestamp: time.Now(),
+	})
+}
+
+// Subscribe returns a channel receiving the events published by the room
+// until it is passed to Unsubscribe. Events that don't fit the buffer of a
+// subscriber not keeping up are dropped, the room never waits for it.
+func (r *room) Subscribe() chan model.Event {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	ch := make(chan model.Event, roomEventBufferSize)
+	r.subscribers[ch] = subscription{}
+	return ch
+}
+
+// SubscribeWithHistory also returns the history up to the first event
+// delivered on the channel, nothing is missed or seen twice. A subscriber
+// falling behind by a full buffer receives an EventDropped and then finds
+// its channel closed, it never misses events without knowing.
+func (r *room) SubscribeWithHistory() ([]model.Message, chan model.Event) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	ch := make(chan model.Event, roomEventBufferSize)
+	r.subscribers[ch] = subscription{closeWhenBehind: true}
+	return slices.Clone(r.messages), ch
+}
+
+// Listen calls fn with every event the room publishes, right away and with
+// the room locked, so fn must return quickly and must not use the room. For
+// consumers that can't afford to lose events, e.g. webhooks.
+func (r *room) Listen(fn func(model.Event)) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.listeners = append(r.listeners, fn)
+}
+
+func (r *room) Unsubscribe(ch chan model.Event) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.subscribers[ch]; ok {
+		delete(r.subscribers, ch)
+		close(ch)
+	}
+}
+
+// Close ends every subscription, subscribers see their channel closed
+func (r *room) Close() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for ch := range r.subscribers {
+		delete(r.subscribers, ch)
+		close(ch)
+	}
+}
+
+// Must be called with the mutex held
+func (r *room) publish(event model.Event) {
+	for _, listener := range r.listeners {
+		listener(event)
+	}
+
+	for ch, sub := range r.subscribers {
+		if !sub.closeWhenBehind {
+			select {
+			case ch <- event:
+			default:
+				// Subscriber is not keeping up, drop rather than block the room
+			}
+			continue
+		}
+
+		// Only the room sends, the last slot is always free for the marker
+		if len(ch) < cap(ch)-1 {
+			ch <- event
+			continue
+		}
+		ch <- model.Event{Kind: model.EventDropped, RoomId: r.roomId, Timestamp: time.Now()}
+		delete(r.subscribers, ch)
+		close(ch)
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/NaiKiDEV/ssh-chat/internal/search"
+	"github.com/NaiKiDEV/ssh-chat/views/chat"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Chat command searching the history of every room
+const searchCommand = "search"
+
+// Upper bound of the results listed for one search
+const maxSearchResults = 100
+
+// Every message posted since the start, filled by room.AddMessage
+var searchIndex = search.NewIndex()
+
+func (m clientState) search(text string) (clientState, tea.Cmd, error) {
+	query, err := search.ParseQuery(text)
+	if err != nil {
+		return m, nil, err
+	}
+	results := searchIndex.Search(query, maxSearchResults)
+	if len(results) == 0 {
+		return m, nil, fmt.Errorf("no messages match %q", strings.TrimSpace(text))
+	}
+
+	if m.accessible {
+		return m, tea.Println(chat.FormatPlainSearchResults(results)), nil
+	}
+	m.chatState = m.chatState.ShowSearchResults(strings.TrimSpace(text), query.Terms, results)
+	return m, nil, nil
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NaiKiDEV/ssh-chat/internal/audit"
+	"github.com/NaiKiDEV/ssh-chat/internal/drafts"
+	"github.com/NaiKiDEV/ssh-chat/internal/model"
+	"github.com/NaiKiDEV/ssh-chat/internal/plugin"
+	"github.com/charmbracelet/ssh"
+	"github.com/charmbracelet/wish"
+)
+
+// Server notice kinds
+const (
+	noticeShutdown     = "shutdown"
+	noticeAnnouncement = "announcement"
+)
+
+// Server wide notice delivered to every interactive session regardless of
+// the room it is in
+type serverNotice struct {
+	Kind string
+	Text string
+	// Who sent it, only set for noticeAnnouncement
+	From string
+	// When the server goes away, only set for noticeShutdown
+	Deadline time.Time
+}
+
+type noticeHub struct {
+	mutex       sync.Mutex
+	subscribers map[chan serverNotice]struct{}
+	// Replayed to sessions connecting after the shutdown started
+	shutdown *serverNotice
+}
+
+func (h *noticeHub) Subscribe() chan serverNotice {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.subscribers == nil {
+		h.subscribers = map[chan serverNotice]struct{}{}
+	}
+	ch := make(chan serverNotice, 8)
+	h.subscribers[ch] = struct{}{}
+	if h.shutdown != nil {
+		ch <- *h.shutdown
+	}
+	return ch
+}
+
+func (h *noticeHub) Unsubscribe(ch chan serverNotice) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if _, ok := h.subscribers[ch]; ok {
+		delete(h.subscribers, ch)
+		close(ch)
+	}
+}
+
+func (h *noticeHub) Broadcast(notice serverNotice) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if notice.Kind == noticeShutdown {
+		h.shutdown = &notice
+	}
+	for ch := range h.subscribers {
+		select {
+		case ch <- notice:
+		default:
+		}
+	}
+}
+
+// Count of sessions currently listening
+func (h *noticeHub) Len() int {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return len(h.subscribers)
+}
+
+// Names of the users with an interactive session, counted as a user may
+// connect more than once, and the nicks held by IRC clients. A name belongs
+// to either side at a time. Names are compared the way IRC compares nicks.
+type userRegistry struct {
+	mutex    sync.Mutex
+	names    map[string]int
+	ircNicks map[string]struct{}
+}
+
+// Add counts a session of userName, false when an IRC client holds the name
+func (r *userRegistry) Add(userName string) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	name := strings.ToLower(userName)
+	if _, held := r.ircNicks[name]; held {
+		return false
+	}
+	if r.names == nil {
+		r.names = map[string]int{}
+	}
+	r.names[name]++
+	return true
+}
+
+func (r *userRegistry) Remove(userName string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	name := strings.ToLower(userName)
+	if r.names[name] <= 1 {
+		delete(r.names, name)
+		return
+	}
+	r.names[name]--
+}
+
+// ClaimIRCNick moves an IRC client from its previous nick, empty for none,
+// to nick. False when nick is online over SSH or held by another client.
+func (r *userRegistry) ClaimIRCNick(nick string, previous string) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	name := strings.ToLower(nick)
+	if _, held := r.ircNicks[name]; held || r.names[name] > 0 {
+		return false
+	}
+	if r.ircNicks == nil {
+		r.ircNicks = map[string]struct{}{}
+	}
+	delete(r.ircNicks, strings.ToLower(previous))
+	r.ircNicks[name] = struct{}{}
+	return true
+}
+
+func (r *userRegistry) ReleaseIRCNick(nick string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	delete(r.ircNicks, strings.ToLower(nick))
+}
+
+// Drafts and sent messages of every user, keyed by clientSession.Identity
+var userInput = drafts.NewStore()
+
+type clientSessionKey struct{}
+
+// clientSession is shared by every copy of a clientState, it holds what has
+// to be released once the program exits no matter how it exits
+type clientSession struct {
+	mutex sync.Mutex
+
+	id         string
+	userName   string
+	remoteAddr string
+	// Empty unless the client proved it holds the key, offering one is not
+	// enough
+	fingerprint string
+	roomId      string
+	roomEvents  chan model.Event
+	notices     chan serverNotice
+	// Closed once the last queued chat input is posted
+	lastPost chan struct{}
+}
+
+func newClientSession(s ssh.Session) (*clientSession, error) {
+	// Nobody checks who is behind an IRC connection, neither side may take
+	// a name the other one uses
+	if !serverState.users.Add(s.User()) {
+		return nil, fmt.Errorf("%s is connected over IRC, log in with another name", s.User())
+	}
+
+	session := &clientSession{
+		id:          s.Context().SessionID(),
+		userName:    s.User(),
+		remoteAddr:  s.RemoteAddr().String(),
+		notices:     serverState.notices.Subscribe(),
+		fingerprint: verifiedFingerprint(s.Context()),
+	}
+	s.Context().SetValue(clientSessionKey{}, session)
+	return session, nil
+}
+
+func (cs *clientSession) JoinRoom(serverRoom *room) chan model.Event {
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+
+	serverRoom.AddActiveUser(cs.userName)
+	cs.roomId = serverRoom.roomId
+	cs.roomEvents = serverRoom.Subscribe()
+	auditRoomPresence(audit.TypeRoomJoin, cs.id, cs.userName, cs.remoteAddr, cs.roomId)
+	return cs.roomEvents
+}
+
+func (cs *clientSession) LeaveRoom() {
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+
+	if cs.roomId == "" {
+		return
+	}
+	if serverRoom := serverState.Room(cs.roomId); serverRoom != nil {
+		serverRoom.Unsubscribe(cs.roomEvents)
+		serverRoom.RemoveActiveUser(cs.userName)
+		auditRoomPresence(audit.TypeRoomLeave, cs.id, cs.userName, cs.remoteAddr, cs.roomId)
+	}
+	cs.roomId = ""
+	cs.roomEvents = nil
+}
+
+// Identity tells users apart across sessions by the key they proved to hold.
+// Names can be picked by anyone, keyless users get one lasting as long as the
+// session.
+func (cs *clientSession) Identity() string {
+	if cs.fingerprint != "" {
+		return cs.fingerprint
+	}
+	return "session:" + cs.id
+}
+
+// Chains chat input posts, each waits for previous to close and closes done
+// once posted
+func (cs *clientSession) queuePost() (previous chan struct{}, done chan struct{}) {
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+
+	previous, done = cs.lastPost, make(chan struct{})
+	cs.lastPost = done
+	return previous, done
+}
+
+func (cs *clientSession) Announce(text string) error {
+	return announce(cs.id, cs.userName, cs.remoteAddr, cs.fingerprint, text)
+}
+
+// Commands offered to the session, built-in ones before the plugin ones
+func (cs *clientSession) Commands() []plugin.Command {
+	commands := []plugin.Command{}
+	if isAdmin(cs.fingerprint) {
+		commands = append(commands, plugin.Command{
+			Name:        announceCommand,
+			Usage:       announceCommand + " <text>",
+			Description: "announce to every session",
+		})
+	}
+	commands = append(commands, plugin.Command{
+		Name:        searchCommand,
+		Usage:       searchCommand + " <words> [from:user] [in:room] [before:date] [after:date]",
+		Description: "search the messages of every room",
+	})
+	return append(commands, serverState.plugins.Commands()...)
+}
+
+func (cs *clientSession) Close() {
+	cs.LeaveRoom()
+	serverState.notices.Unsubscribe(cs.notices)
+	serverState.users.Remove(cs.userName)
+	if cs.fingerprint == "" {
+		userInput.Forget(cs.Identity())
+	}
+}
+
+// Runs after the bubbletea program of a session exited, also when the client
+// just dropped the connection
+func sessionCleanupMiddleware() wish.Middleware {
+	return func(next ssh.Handler) ssh.Handler {
+		return func(s ssh.Session) {
+			if session, ok := s.Context().Value(clientSessionKey{}).(*clientSession); ok {
+				session.Close()
+				if serverState.shuttingDown.Load() {
+					wish.Println(s, "The server is shutting down, see you soon!")
+				}
+			}
+			next(s)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/NaiKiDEV/ssh-chat/internal/webhook"
+	"github.com/charmbracelet/log"
+	"github.com/charmbracelet/ssh"
+)
+
+// Time the listeners get to finish once sessions had their grace period
+const shutdownTimeout = 30 * time.Second
+
+// Warns every session, waits for the grace period (or until everyone left)
+// and only then stops the listeners and flushes what is still buffered
+func shutdownGracefully(s *ssh.Server, httpServer *http.Server, metricsServer *http.Server, webhooks *webhook.Dispatcher) {
+	log.Info("Stopping SSH server", "grace", *shutdownGrace)
+	serverState.shuttingDown.Store(true)
+
+	deadline := time.Now().Add(*shutdownGrace)
+	text := fmt.Sprintf("The server is shutting down in %d seconds.", int(shutdownGrace.Seconds()))
+	serverState.notices.Broadcast(serverNotice{
+		Kind:     noticeShutdown,
+		Text:     text,
+		Deadline: deadline,
+	})
+	if serverState.irc != nil {
+		serverState.irc.Notice(text)
+	}
+
+	// Interactive sessions quit on their own once the deadline passes
+	for time.Now().Before(deadline) && serverState.notices.Len() > 0 {
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	// Ends tail, event stream and IRC relays
+	for _, serverRoom := range serverState.Rooms() {
+		serverRoom.Close()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if metricsServer != nil {
+		if err := metricsServer.Shutdown(ctx); err != nil {
+			log.Error("Could not stop metrics listener", "error", err)
+		}
+	}
+	if serverState.irc != nil {
+		if err := serverState.irc.Close(); err != nil {
+			log.Error("Could not stop IRC gateway", "error", err)
+		}
+	}
+	if httpServer != nil {
+		if err := httpServer.Shutdown(ctx); err != nil {
+			log.Error("Could not stop HTTP API", "error", err)
+		}
+	}
+	if err := s.Shutdown(ctx); err != nil && !errors.Is(err, ssh.ErrServerClosed) {
+		log.Error("Could not stop server gracefully, closing remaining sessions", "error", err)
+		_ = s.Close()
+	}
+
+	webhooks.Close(ctx)
+	if err := auditLog.Sync(); err != nil {
+		log.Error("Could not flush audit log", "error", err)
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/NaiKiDEV/ssh-chat/internal/model"
+	"github.com/charmbracelet/ssh"
+)
+
+// Line feed output formats
+const (
+	tailFormatPlain = "plain"
+	tailFormatJSON  = "json"
+)
+
+var errTailBehind = errors.New("stopped, the output was read too slowly and events were dropped")
+
+// Streams a room as lines: `ssh chat-host tail [-n 20] [-format json] <room>`
+func runTailCommand(s ssh.Session, args []string) error {
+	flags := flag.NewFlagSet("tail", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	historySize := flags.Int("n", 20, "number of history messages printed before streaming")
+	format := flags.String("format", tailFormatPlain, "output format, plain or json")
+	follow := flags.Bool("follow", true, "keep streaming new events after the history")
+
+	// Flags are accepted both before and after the room id
+	if err := flags.Parse(args); err != nil || flags.NArg() < 1 {
+		return errUsage
+	}
+	roomId := flags.Arg(0)
+	if err := flags.Parse(flags.Args()[1:]); err != nil || flags.NArg() != 0 {
+		return errUsage
+	}
+
+	var writeEvent func(w io.Writer, event model.Event) error
+	switch *format {
+	case tailFormatPlain:
+		writeEvent = writePlainEvent
+	case tailFormatJSON:
+		encoder := json.NewEncoder(s)
+		writeEvent = func(_ io.Writer, event model.Event) error {
+			return encoder.Encode(event)
+		}
+	default:
+		return fmt.Errorf("unknown format %q", *format)
+	}
+
+	serverRoom, err := findRoom(roomId)
+	if err != nil {
+		return err
+	}
+
+	history, events := serverRoom.SubscribeWithHistory()
+	defer serverRoom.Unsubscribe(events)
+
+	if *historySize >= 0 && len(history) > *historySize {
+		history = history[len(history)-*historySize:]
+	}
+	for _, msg := range history {
+		if err := writeEvent(s, messageEvent(roomId, msg)); err != nil {
+			return err
+		}
+	}
+
+	if !*follow {
+		return nil
+	}
+
+	for {
+		select {
+		case <-s.Context().Done():
+			return nil
+		case event, ok := <-events:
+			if !ok {
+				return nil
+			}
+			if err := writeEvent(s, event); err != nil {
+				return err
+			}
+			if event.Kind == model.EventDropped {
+				return errTailBehind
+			}
+		}
+	}
+}
+
+func messageEvent(roomId string, msg model.Message) model.Event {
+	return model.Event{
+		Kind:      model.EventMessage,
+		RoomId:    roomId,
+		Username:  msg.Username,
+		Message:   &msg,
+		Timestamp: msg.Timestamp,
+	}
+}
+
+// One line per event, continuation lines of multi-line messages are indented
+// so line based tools can still tell messages apart
+func writePlainEvent(w io.Writer, event model.Event) error {
+	timestamp := event.Timestamp.UTC().Format(time.RFC3339)
+
+	var line string
+	switch event.Kind {
+	case model.EventMessage:
+		text := strings.ReplaceAll(event.Message.PlainText(), "\n", "\n  ")
+		line = fmt.Sprintf("%s %s %s: %s", timestamp, event.RoomId, event.Message.DisplayName(), text)
+	case model.EventJoin:
+		line = fmt.Sprintf("%s %s * %s joined", timestamp, event.RoomId, event.Username)
+	case model.EventLeave:
+		line = fmt.Sprintf("%s %s * %s left", timestamp, event.RoomId, event.Username)
+	case model.EventTopic:
+		line = fmt.Sprintf("%s %s * %s changed the topic to: %s", timestamp, event.RoomId, event.Username, event.Topic)
+	case model.EventDropped:
+		line = fmt.Sprintf("%s %s * events dropped, reading fell behind", timestamp, event.RoomId)
+	default:
+		return nil
+	}
+
+	_, err := fmt.Fprintln(w, line)
+	return err
+}
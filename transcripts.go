@@ -0,0 +1,163 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/NaiKiDEV/ssh-chat/internal/model"
+	"github.com/NaiKiDEV/ssh-chat/internal/transcript"
+	"github.com/charmbracelet/log"
+	"github.com/charmbracelet/ssh"
+	"github.com/charmbracelet/wish"
+	"github.com/charmbracelet/wish/scp"
+	"github.com/pkg/sftp"
+)
+
+// Rooms as seen by the transcript file system
+type roomSource struct{}
+
+func (roomSource) RoomIds() []string {
+	roomIds := []string{}
+	for _, serverRoom := range serverState.Rooms() {
+		roomIds = append(roomIds, serverRoom.roomId)
+	}
+	return roomIds
+}
+
+func (roomSource) Messages(roomId string) ([]model.Message, bool) {
+	serverRoom := serverState.Room(roomId)
+	if serverRoom == nil {
+		return nil, false
+	}
+	return serverRoom.Messages(), true
+}
+
+func (roomSource) Files(roomId string) fs.FS {
+	if attachments == nil {
+		return nil
+	}
+	return attachments.FS(roomId)
+}
+
+// Turns what clients ask for, e.g. "/rooms/public" or "rooms/public/", into
+// a path of the transcript file system
+func transcriptPath(name string) string {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if name == "" {
+		return "."
+	}
+	return name
+}
+
+// Serves `scp -O chat-host:rooms/public/2024-05-01.md .` and uploads of
+// attachments, clients using the SFTP protocol end up in transcriptSFTPHandler
+func transcriptSCPMiddleware() wish.Middleware {
+	return scp.Middleware(transcriptSCPHandler{scp.NewFSReadHandler(transcript.NewFS(roomSource{}))}, attachmentSCPHandler{})
+}
+
+type transcriptSCPHandler struct {
+	scp.CopyToClientHandler
+}
+
+func (h transcriptSCPHandler) Glob(s ssh.Session, pattern string) ([]string, error) {
+	return h.CopyToClientHandler.Glob(s, transcriptPath(pattern))
+}
+
+// Subsystem handler serving the transcript file system over SFTP, which is
+// what scp uses by default since OpenSSH 9.0. Writing is limited to sharing
+// attachments.
+func transcriptSFTPHandler(s ssh.Session) {
+	handler := sftpHandler{fsys: transcript.NewFS(roomSource{}), userName: s.User()}
+	server := sftp.NewRequestServer(s, sftp.Handlers{
+		FileGet:  handler,
+		FilePut:  handler,
+		FileCmd:  handler,
+		FileList: handler,
+	})
+	defer server.Close()
+
+	if err := server.Serve(); err != nil && !errors.Is(err, io.EOF) {
+		log.Error("SFTP session failed", "user", s.User(), "error", err)
+		_ = s.Exit(1)
+		return
+	}
+	_ = s.Exit(0)
+}
+
+type sftpHandler struct {
+	fsys     fs.FS
+	userName string
+}
+
+func (h sftpHandler) Fileread(r *sftp.Request) (io.ReaderAt, error) {
+	file, err := h.fsys.Open(transcriptPath(r.Filepath))
+	if err != nil {
+		return nil, err
+	}
+	readerAt, ok := file.(io.ReaderAt)
+	if !ok {
+		_ = file.Close()
+		return nil, sftp.ErrSSHFxFailure
+	}
+	return readerAt, nil
+}
+
+func (h sftpHandler) Filewrite(r *sftp.Request) (io.WriterAt, error) {
+	serverRoom, upload, err := startUpload(r.Filepath, "")
+	if err != nil {
+		return nil, err
+	}
+	return &sftpUpload{Upload: upload, userName: h.userName, serverRoom: serverRoom}, nil
+}
+
+func (h sftpHandler) Filecmd(r *sftp.Request) error {
+	// Clients like to set permissions and times after uploading, ignore it
+	if r.Method == "Setstat" {
+		return nil
+	}
+	return sftp.ErrSSHFxPermissionDenied
+}
+
+func (h sftpHandler) Filelist(r *sftp.Request) (sftp.ListerAt, error) {
+	name := transcriptPath(r.Filepath)
+	switch r.Method {
+	case "List":
+		entries, err := fs.ReadDir(h.fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		infos := make(listerAt, 0, len(entries))
+		for _, entry := range entries {
+			info, err := entry.Info()
+			if err != nil {
+				return nil, err
+			}
+			infos = append(infos, info)
+		}
+		return infos, nil
+	case "Stat", "Lstat":
+		info, err := fs.Stat(h.fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		return listerAt{info}, nil
+	}
+	return nil, sftp.ErrSSHFxOpUnsupported
+}
+
+type listerAt []os.FileInfo
+
+func (l listerAt) ListAt(infos []os.FileInfo, offset int64) (int, error) {
+	if offset >= int64(len(l)) {
+		return 0, io.EOF
+	}
+	n := copy(infos, l[offset:])
+	if n < len(infos) {
+		return n, io.EOF
+	}
+	return n, nil
+}
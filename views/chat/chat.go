@@ -1,17 +1,21 @@
 package chat
 
 import (
-	"fmt"
 	"strings"
+	"unicode"
 
-	"github.com/NaiKiDEV/ssh-chat/internal/consts"
+	"github.com/NaiKiDEV/ssh-chat/internal/keymap"
 	"github.com/NaiKiDEV/ssh-chat/internal/model"
+	"github.com/NaiKiDEV/ssh-chat/internal/plugin"
 	"github.com/NaiKiDEV/ssh-chat/internal/styles"
 	"github.com/NaiKiDEV/ssh-chat/internal/terminal"
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/textarea"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/mattn/go-runewidth"
+	"github.com/rivo/uniseg"
 )
 
 const (
@@ -33,42 +37,112 @@ const (
 	buttonGap                   = 1
 	formGap                     = 2
 	containerXPadding           = 1
+	minComposerHeight           = 2
+	maxComposerLines            = 99
+	// Hint line and gap above the full screen composer
+	expandedComposerOffset = 2
+	// Title and gap above the key bindings
+	helpHeaderHeight = 2
+	// Narrower terminals hide the online users unless toggled on
+	sidebarBreakpoint = 64
+	// Shorter terminals get a single line header instead of the logo
+	logoBreakpoint      = 24
+	compactHeaderHeight = 2
+	// Anything smaller shows a notice instead of the layout
+	minWidth  = 40
+	minHeight = 12
+)
+
+// Sidebar visibility, automatic until toggled
+const (
+	sidebarAuto = iota
+	sidebarShown
+	sidebarHidden
 )
 
 type ChatState struct {
 	chatInput         textarea.Model
 	chatInputExpanded bool
+	composerHeight    int
+	width             int
+	height            int
+	accessible        bool
 	chatViewport      viewport.Model
 	contentHeight     int
-	activeInputId     int
-	messages          []model.Message
-	activeUsers       []string
-	roomId            string
-	userName          string
-	clientStyles      *styles.ClientStyles
+	sidebar           int
+	// Whether the viewport sticks to the newest message
+	following bool
+	// Messages arrived below the viewport while not following
+	unseen        int
+	activeInputId int
+	messages      []model.Message
+	activeUsers   []string
+	notice        string
+	noticeIsError bool
+	roomId        string
+	topic         string
+	userName      string
+	search        searchState
+	highlight     *messageHighlight
+	emoji         emojiCompletion
+	completion    tabCompletion
+	rooms         []string
+	commands      []plugin.Command
+	history       inputHistory
+	keyMap        *keymap.KeyMap
+	// Key bindings listed in place of everything else
+	showHelp     bool
+	helpOffset   int
+	clientStyles *styles.ClientStyles
 }
 
 func NewChatState(userName string, ts *terminal.TerminalState, cs *styles.ClientStyles) ChatState {
-	chatInput := createAreaInput("Type your message...", 0, ts.Width-sendButtonSize-leaveButtonSize-buttonGap-containerXPadding*2-formGap)
+	chatInput := createAreaInput("Type your message...", 0, composerWidth(ts.Width))
 	chatInput.Focus()
 
-	contentOffset := chatInput.Height() + logoOffset + messageBoxOffset
-	contentHeight := ts.Height - contentOffset
-
-	chatViewport := viewport.New(ts.Width-onlineUsersContainerPadding*2-onlineUsersContainerWidth-onlineUsersContainerBorder, contentHeight)
-	chatViewport.YPosition = logoOffset
-	chatViewport.Height = contentHeight
+	chatViewport := viewport.New(0, 0)
 	chatViewport.MouseWheelEnabled = true
 
-	return ChatState{
+	c := ChatState{
 		userName:          userName,
 		chatInput:         chatInput,
 		chatViewport:      chatViewport,
 		activeInputId:     chatInputId,
-		contentHeight:     contentHeight,
 		chatInputExpanded: false,
+		composerHeight:    minComposerHeight,
+		following:         true,
+		width:             ts.Width,
+		height:            ts.Height,
 		clientStyles:      cs,
 	}
+	return c.SetKeyMap(keymap.Default()).layout()
+}
+
+func (c ChatState) SetKeyMap(km *keymap.KeyMap) ChatState {
+	c.keyMap = km
+	c.chatViewport.KeyMap = viewport.KeyMap{
+		Up:           km.Chat.ScrollUp,
+		Down:         km.Chat.ScrollDown,
+		PageUp:       km.Chat.PageUp,
+		PageDown:     km.Chat.PageDown,
+		HalfPageUp:   km.Chat.HalfPageUp,
+		HalfPageDown: km.Chat.HalfPageDown,
+	}
+	return c
+}
+
+func composerWidth(terminalWidth int) int {
+	return inputBoxWidth(terminalWidth) - formGap
+}
+
+func inputBoxWidth(terminalWidth int) int {
+	return terminalWidth - sendButtonSize - leaveButtonSize - buttonGap - containerXPadding*2
+}
+
+// Rows the input grows to before it scrolls
+func (c ChatState) SetComposerHeight(height int) ChatState {
+	c.composerHeight = max(height, minComposerHeight)
+	return c.layout()
 }
 
 func (c ChatState) Init() tea.Cmd {
@@ -77,18 +151,49 @@ func (c ChatState) Init() tea.Cmd {
 
 func (c ChatState) Reset() ChatState {
 	c.chatInput.SetValue("")
+	c.emoji = emojiCompletion{}
+	c.completion = tabCompletion{}
 	c.chatInput.Focus()
 	c.activeInputId = chatInputId
-	return c
+	c.chatInputExpanded = false
+	return c.layout()
 }
 
 func (c ChatState) SetRoom(roomId string, messages []model.Message) ChatState {
 	c.messages = messages
 	c.roomId = roomId
+	c.highlight = nil
+	c.following = true
+	c.unseen = 0
+	return c
+}
+
+// Switches between the regular layout and the linear plain text mode
+func (c ChatState) SetAccessible(accessible bool) ChatState {
+	c.accessible = accessible
+	if accessible {
+		c.activeInputId = chatInputId
+		c.chatInput.Focus()
+	}
+	return c
+}
+
+// Shows a status next to the logo, an empty notice clears it
+func (c ChatState) SetNotice(notice string, isError bool) ChatState {
+	c.notice = notice
+	c.noticeIsError = isError
+	return c
+}
+
+func (c ChatState) SetTopic(topic string) ChatState {
+	c.topic = topic
 	return c
 }
 
 func (c ChatState) SetChatState(messages []model.Message, activeUsers []string) ChatState {
+	if !c.following && len(messages) > len(c.messages) {
+		c.unseen += len(messages) - len(c.messages)
+	}
 	c.activeUsers = activeUsers
 	c.messages = messages
 	return c
@@ -130,56 +235,136 @@ func (c ChatState) focusNextFocusableElement(backwards bool) ChatState {
 	return c
 }
 
+// The input height follows its content, so the layout is redone after every
+// update
 func (c ChatState) Update(msg tea.Msg) (ChatState, tea.Cmd) {
+	c, cmd := c.update(msg)
+	return c.layout(), cmd
+}
+
+func (c ChatState) update(msg tea.Msg) (ChatState, tea.Cmd) {
 	var inCmd tea.Cmd
 	var vpCmd tea.Cmd
 
+	if c.accessible {
+		return c.updatePlain(msg)
+	}
+
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		if c.showHelp {
+			return c.updateHelp(msg), nil
+		}
+		if key.Matches(msg, c.keyMap.Global.Help) && !c.typing(msg) {
+			c.showHelp = true
+			c.helpOffset = 0
+			return c, nil
+		}
+	}
+
+	if c.search.open {
+		if msg, ok := msg.(tea.KeyMsg); ok {
+			return c.updateSearch(msg)
+		}
+		return c, nil
+	}
+
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
-		switch msg.Type {
-		case tea.KeyTab:
-			c = c.focusNextFocusableElement(false)
-			return c, nil
-		case tea.KeyShiftTab:
-			c = c.focusNextFocusableElement(true)
+		km := c.keyMap
+		switch {
+		case key.Matches(msg, km.Chat.JumpToNew):
+			return c.jumpToBottom(), nil
+		case key.Matches(msg, km.Chat.ToggleSidebar):
+			c.sidebar = sidebarShown
+			if c.sidebarVisible() {
+				c.sidebar = sidebarHidden
+			}
 			return c, nil
-		case tea.KeyEnter:
-			if c.activeInputId == noneId {
-				c.activeInputId = chatInputId
-				c.chatInput.Focus()
+		}
+
+		if c.emoji.open() && c.activeInputId == chatInputId {
+			var handled bool
+			if c, handled = c.updateEmojiKeys(msg); handled {
 				return c, nil
 			}
-			if c.activeInputId == sendButtonId {
-				if value := c.chatInput.Value(); value != "" {
-					c.chatInput.SetValue("")
-					c.chatInput.Focus()
-					c.activeInputId = chatInputId
-					return c, createMessageSentCmd(value)
-				}
+		}
+
+		if c.completion.open() && c.activeInputId == chatInputId {
+			var handled bool
+			if c, handled = c.updateCompletionKeys(msg); handled {
+				return c, nil
 			}
-			if c.activeInputId == leaveButtonId {
-				return c, createLeaveChatCmd()
+		}
+
+		if c.activeInputId == chatInputId {
+			if key.Matches(msg, km.Completion.Next, km.Completion.Previous) {
+				return c.startCompletion(key.Matches(msg, km.Completion.Previous)), nil
+			}
+
+			var recalled bool
+			if c, recalled = c.recallHistory(msg); recalled {
+				return c, nil
 			}
 
-			c.chatInput, inCmd = c.chatInput.Update(msg)
-			return c, inCmd
-		case tea.KeyEsc:
+			switch {
+			case key.Matches(msg, km.Chat.Send):
+				return c.send()
+			case key.Matches(msg, km.Chat.Newline):
+				c.chatInput, inCmd = c.chatInput.Update(tea.KeyMsg{Type: tea.KeyEnter})
+				return c, inCmd
+			case key.Matches(msg, km.Chat.Compose):
+				c.chatInputExpanded = !c.chatInputExpanded
+				return c, nil
+			}
+		}
+		if c.chatInputExpanded {
+			switch {
+			case key.Matches(msg, km.Chat.Blur):
+				c.chatInputExpanded = false
+				return c, nil
+			case key.Matches(msg, km.Chat.NextFocus, km.Chat.PreviousFocus):
+				// Nothing else to focus on the full screen composer
+				return c, nil
+			}
+		}
+
+		switch {
+		case key.Matches(msg, km.Chat.NextFocus):
+			c = c.focusNextFocusableElement(false)
+			return c, nil
+		case key.Matches(msg, km.Chat.PreviousFocus):
+			c = c.focusNextFocusableElement(true)
+			return c, nil
+		case key.Matches(msg, km.Chat.Blur):
 			c.chatInput.Blur()
 			c.activeInputId = noneId
 			return c, nil
-		default:
-			c, inCmd = c.handleInput(msg)
+		case c.activeInputId != chatInputId && key.Matches(msg, km.Chat.Activate):
+			switch c.activeInputId {
+			case noneId:
+				c.activeInputId = chatInputId
+				c.chatInput.Focus()
+				return c, nil
+			case sendButtonId:
+				return c.send()
+			case leaveButtonId:
+				return c, createLeaveChatCmd()
+			}
+		case c.activeInputId == noneId:
+			return c.scroll(msg), nil
 		}
 
+		c, inCmd = c.handleInput(msg)
+		c = c.updateEmojiCompletion()
+
 	case tea.MouseMsg:
 		var cmd tea.Cmd
-		c.chatViewport.SetContent(renderMessageView(c.userName, c.messages, c.clientStyles))
 		if msg.Type == tea.MouseLeft {
-			c.chatViewport.GotoBottom()
-			return c, nil
+			return c.click(msg.X, msg.Y)
 		}
+		c = c.syncViewport()
 		c.chatViewport, cmd = c.chatViewport.Update(msg)
-		return c, cmd
+		return c.updateFollowing(), cmd
 
 	}
 
@@ -190,15 +375,62 @@ func (c ChatState) Update(msg tea.Msg) (ChatState, tea.Cmd) {
 	return c, tea.Batch(inCmd, vpCmd)
 }
 
+func (c ChatState) updateHelp(msg tea.KeyMsg) ChatState {
+	lines := len(helpLines(c.keyMap, c.width-containerXPadding*2, c.clientStyles))
+	maxOffset := max(lines-(c.height-helpHeaderHeight), 0)
+	switch {
+	case key.Matches(msg, c.keyMap.Global.Help, c.keyMap.Global.CloseHelp):
+		c.showHelp = false
+	case key.Matches(msg, c.keyMap.Chat.ScrollUp):
+		c.helpOffset = clamp(c.helpOffset-1, 0, maxOffset)
+	case key.Matches(msg, c.keyMap.Chat.ScrollDown):
+		c.helpOffset = clamp(c.helpOffset+1, 0, maxOffset)
+	}
+	return c
+}
+
+// Printable keys typed into the input are text, not bindings
+func (c ChatState) typing(msg tea.KeyMsg) bool {
+	return c.activeInputId == chatInputId && len(msg.Runes) > 0 && !msg.Alt
+}
+
+// Keys scrolling the messages while nothing is focused
+func (c ChatState) scroll(msg tea.KeyMsg) ChatState {
+	c = c.syncViewport()
+	switch {
+	case key.Matches(msg, c.keyMap.Chat.ScrollToTop):
+		c.chatViewport.GotoTop()
+	case key.Matches(msg, c.keyMap.Chat.ScrollToBottom):
+		c.chatViewport.GotoBottom()
+	default:
+		c.chatViewport, _ = c.chatViewport.Update(msg)
+	}
+	return c.updateFollowing()
+}
+
+func (c ChatState) send() (ChatState, tea.Cmd) {
+	value := c.chatInput.Value()
+	if strings.TrimSpace(value) == "" {
+		return c, nil
+	}
+	c.chatInput.SetValue("")
+	c.chatInput.Focus()
+	c.activeInputId = chatInputId
+	c.chatInputExpanded = false
+	c.highlight = nil
+	c.following = true
+	c.unseen = 0
+	c.emoji = emojiCompletion{}
+	c.completion = tabCompletion{}
+	return c, createMessageSentCmd(value)
+}
+
 func (c ChatState) handleInput(msg tea.Msg) (ChatState, tea.Cmd) {
 	var cmd tea.Cmd
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
-		switch c.activeInputId {
-		case chatInputId:
+		if c.activeInputId == chatInputId {
 			c.chatInput, cmd = c.chatInput.Update(msg)
-		case noneId:
-			c.chatViewport, cmd = c.chatViewport.Update(msg)
 		}
 		return c, cmd
 	}
@@ -206,83 +438,157 @@ func (c ChatState) handleInput(msg tea.Msg) (ChatState, tea.Cmd) {
 }
 
 func (c ChatState) Resize(terminalState *terminal.TerminalState) ChatState {
-	c.chatInput.SetWidth(terminalState.Width)
+	c.width = terminalState.Width
+	c.height = terminalState.Height
+	return c.layout()
+}
 
-	contentOffset := c.chatInput.Height() + logoOffset + messageBoxOffset
-	contentHeight := terminalState.Height - contentOffset
+func (c ChatState) tooSmall() bool {
+	return c.width < minWidth || c.height < minHeight
+}
 
-	c.chatViewport.Width = terminalState.Width - onlineUsersContainerWidth - onlineUsersContainerPadding*2 - onlineUsersContainerBorder
-	c.chatViewport.Height = contentHeight
+// Shown when toggled on if the messages still get minWidth columns
+func (c ChatState) sidebarVisible() bool {
+	switch c.sidebar {
+	case sidebarShown:
+		return c.width-onlineUsersContainerSize >= minWidth
+	case sidebarHidden:
+		return false
+	}
+	return c.width >= sidebarBreakpoint
+}
+
+func (c ChatState) showLogo() bool {
+	return c.height >= logoBreakpoint
+}
+
+func (c ChatState) headerHeight() int {
+	if c.showLogo() {
+		return logoOffset
+	}
+	return compactHeaderHeight
+}
+
+// Sizes the input to its content, up to composerHeight rows, and leaves the
+// rest of the height to the messages. The full screen composer takes it all.
+func (c ChatState) layout() ChatState {
+	if c.chatInputExpanded {
+		c.chatInput.SetWidth(c.width - containerXPadding*2)
+		c.chatInput = resizeAreaInput(c.chatInput, max(c.height-expandedComposerOffset-c.suggestionRows(), minComposerHeight))
+		return c
+	}
 
-	c.contentHeight = contentHeight
+	c.chatInput.SetWidth(composerWidth(c.width))
+	c.chatInput = resizeAreaInput(c.chatInput, clamp(composerRows(c.chatInput), minComposerHeight, c.composerHeight))
 
+	c.chatViewport.Width = c.width
+	if c.sidebarVisible() {
+		c.chatViewport.Width -= onlineUsersContainerSize
+	}
+	contentOffset := c.chatInput.Height() + c.headerHeight() + messageBoxOffset
+	c.contentHeight = max(c.height-contentOffset, 0)
+	c.chatViewport.Height = c.contentHeight
+	c.chatViewport.YPosition = c.headerHeight()
 	return c
 }
 
-func (c ChatState) Render(terminalState *terminal.TerminalState, messages []model.Message, activeUsers []string) string {
-	styles := c.clientStyles
+// Rows the input content takes once soft wrapped
+func composerRows(input textarea.Model) int {
+	rows := 0
+	for _, line := range strings.Split(input.Value(), "\n") {
+		rows += wrappedRows([]rune(line), max(input.Width(), 1))
+	}
+	return rows
+}
+
+// Rows a line takes in the textarea. Follows its word wrapping, which is not
+// exported, including the extra row for the cursor once a line is full.
+func wrappedRows(line []rune, width int) int {
+	var (
+		rows   = 1
+		row    []rune
+		word   []rune
+		spaces int
+	)
+	for _, r := range line {
+		if unicode.IsSpace(r) {
+			spaces++
+		} else {
+			word = append(word, r)
+		}
 
-	// Header
-	logo := lipgloss.NewStyle().
-		Foreground(styles.PrimaryColor).
-		Padding(0, 1, 1).
-		Render(consts.LOGO_NO_MARGIN)
-
-	header := lipgloss.NewStyle().
-		Width(terminalState.Width - onlineUsersContainerSize).
-		Render(lipgloss.JoinVertical(lipgloss.Top, logo))
-
-	// Online Users Card
-	styledActiveUsers := strings.Builder{}
-	clampedActiveUsersCount := clamp(len(activeUsers), 0, c.contentHeight-1)
-	for _, user := range activeUsers[:clampedActiveUsersCount] {
-		labelColor := styles.GreyColor
-		if user == c.userName {
-			labelColor = styles.PrimaryColor
+		if spaces > 0 {
+			if uniseg.StringWidth(string(row))+uniseg.StringWidth(string(word))+spaces > width {
+				rows++
+				row = nil
+			}
+			row = append(append(row, word...), []rune(strings.Repeat(" ", spaces))...)
+			spaces = 0
+			word = nil
+		} else if uniseg.StringWidth(string(word))+runewidth.RuneWidth(word[len(word)-1]) > width {
+			// A word longer than the line is broken up
+			if len(row) > 0 {
+				rows++
+				row = nil
+			}
+			row = append(row, word...)
+			word = nil
 		}
-		onlineUserText := styles.BoldRegularTxt.Foreground(labelColor).Render(user)
-		styledActiveUsers.WriteString(onlineUserText + "\n")
 	}
 
-	sideBarLabelTextStyle := lipgloss.NewStyle().Width(onlineUsersContainerWidth).Bold(true)
+	if uniseg.StringWidth(string(row))+uniseg.StringWidth(string(word))+spaces >= width {
+		rows++
+	}
+	return rows
+}
+
+func (c ChatState) Render(terminalState *terminal.TerminalState, messages []model.Message, activeUsers []string) string {
+	styles := c.clientStyles
 
-	var roomText string
-	if c.roomId != "" {
-		roomLabel := styles.BoldRegularTxt.Render("Room:")
-		roomId := styles.RegularTxt.Foreground(styles.PrimaryColor).Underline(true).Render(c.roomId)
-		roomText = lipgloss.NewStyle().MarginTop(2).Render(roomLabel, roomId) + "\n\n\n"
+	if c.tooSmall() {
+		return styles.RenderTooSmall(terminalState.Width, terminalState.Height, minWidth, minHeight)
+	}
+	if c.showHelp {
+		return renderHelp(c, terminalState, styles)
+	}
+	if c.chatInputExpanded {
+		return renderExpandedComposer(c, terminalState, styles)
 	}
 
-	activeUsersCountText := sideBarLabelTextStyle.Render(fmt.Sprintf("Online Count: %d\n", len(activeUsers)))
+	header := c.renderHeader(len(activeUsers), styles)
 
-	onlineUsersLabelText := ""
-	styledActiveUsersString := styledActiveUsers.String()
-	if styledActiveUsersString != "" {
-		onlineUsersLabelText = sideBarLabelTextStyle.Render("Online Users:\n")
-		styledActiveUsersString += "\n"
+	onlineUsersContainer := c.renderSidebar(activeUsers, styles)
+
+	// Suggestions cover the bottom of the messages, right above the input
+	suggestions := c.renderSuggestions(c.chatViewport.Width)
+	if suggestions != "" {
+		c.chatViewport.Height = max(c.chatViewport.Height-lipgloss.Height(suggestions), 0)
 	}
-	onlineUsersContainer := lipgloss.NewStyle().
-		Height(c.contentHeight+logoOffset).
-		Width(onlineUsersContainerWidth).
-		Padding(0, onlineUsersContainerPadding, 1).
-		BorderStyle(lipgloss.NormalBorder()).
-		BorderLeft(true).
-		Render(roomText + activeUsersCountText + onlineUsersLabelText + styledActiveUsersString)
 
-	c.chatViewport.SetContent(renderMessageView(c.userName, messages, styles))
+	c.chatViewport.SetContent(renderMessageView(c.userName, messages, c.highlight, c.chatViewport.Width, styles))
+	if c.following {
+		c.chatViewport.GotoBottom()
+	}
+	messageView := renderScrollState(c.chatViewport, c.chatViewport.View(), c.unseen, keymap.FirstKey(c.keyMap.Chat.JumpToNew), styles)
+	if c.search.open {
+		messageView = renderSearchResults(c.search, c.keyMap.Search, c.chatViewport.Width, c.chatViewport.Height, styles)
+	}
+	if suggestions != "" {
+		messageView = lipgloss.JoinVertical(lipgloss.Left, messageView, suggestions)
+	}
 
 	// Input Box
 	inputBox := lipgloss.NewStyle().
-		Width(terminalState.Width - sendButtonSize - leaveButtonSize - buttonGap - containerXPadding*2).
+		Width(inputBoxWidth(terminalState.Width)).
 		Render(renderAreaInput(c.userName, c.chatInput, styles))
 
 	// Button Group
 	sendButton := renderButton("send", c.activeInputId == sendButtonId, styles)
 	buttonGap := strings.Repeat(" ", buttonGap)
 	leaveButton := renderButton("leave", c.activeInputId == leaveButtonId, styles)
-	buttonGroup := lipgloss.NewStyle().Padding(1, 1).Render(lipgloss.JoinHorizontal(lipgloss.Left, sendButton, buttonGap, leaveButton))
+	buttonGroup := lipgloss.NewStyle().Padding(buttonGroupPaddingTop, buttonGroupPaddingX).Render(lipgloss.JoinHorizontal(lipgloss.Left, sendButton, buttonGap, leaveButton))
 
-	formContainer := lipgloss.NewStyle().Padding(2, containerXPadding, 0)
+	formContainer := lipgloss.NewStyle().Padding(formPaddingTop, containerXPadding, 0)
 	form := formContainer.Render(
 		lipgloss.JoinHorizontal(lipgloss.Left,
 			inputBox,
@@ -290,9 +596,12 @@ func (c ChatState) Render(terminalState *terminal.TerminalState, messages []mode
 			buttonGroup,
 		))
 
-	headerWithViewport := lipgloss.JoinVertical(lipgloss.Top, header, c.chatViewport.View())
+	headerWithViewport := lipgloss.JoinVertical(lipgloss.Top, header, messageView)
 
-	content := lipgloss.JoinHorizontal(lipgloss.Left, headerWithViewport, onlineUsersContainer)
+	content := headerWithViewport
+	if c.sidebarVisible() {
+		content = lipgloss.JoinHorizontal(lipgloss.Left, headerWithViewport, onlineUsersContainer)
+	}
 
 	ui := lipgloss.JoinVertical(lipgloss.Left, content, form)
 
@@ -0,0 +1,180 @@
+package chat
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/NaiKiDEV/ssh-chat/internal/styles"
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/formatters"
+	"github.com/alecthomas/chroma/v2/lexers"
+	chromastyles "github.com/alecthomas/chroma/v2/styles"
+	"github.com/charmbracelet/x/ansi"
+)
+
+const (
+	codeFence = "```"
+	tabWidth  = 4
+	// Highlighted blocks kept around, messages are rendered on every frame
+	maxCachedCodeBlocks = 256
+)
+
+// Part of a message, either prose or the content of a fenced code block
+type textSegment struct {
+	text     string
+	language string
+	isCode   bool
+}
+
+// Splits out ``` fenced blocks, a fence without a closing one stays prose
+func splitCodeBlocks(text string) []textSegment {
+	segments := []textSegment{}
+	prose := []string{}
+	lines := strings.Split(text, "\n")
+
+	flushProse := func() {
+		if len(prose) > 0 {
+			segments = append(segments, textSegment{text: strings.Join(prose, "\n")})
+			prose = nil
+		}
+	}
+
+	for idx := 0; idx < len(lines); idx++ {
+		opening := strings.TrimSpace(lines[idx])
+		if !strings.HasPrefix(opening, codeFence) {
+			prose = append(prose, lines[idx])
+			continue
+		}
+
+		closing := -1
+		for end := idx + 1; end < len(lines); end++ {
+			if strings.TrimSpace(lines[end]) == codeFence {
+				closing = end
+				break
+			}
+		}
+		if closing == -1 {
+			prose = append(prose, lines[idx])
+			continue
+		}
+
+		flushProse()
+		language := ""
+		if fields := strings.Fields(strings.TrimPrefix(opening, codeFence)); len(fields) > 0 {
+			language = fields[0]
+		}
+		segments = append(segments, textSegment{
+			text:     strings.Join(lines[idx+1:closing], "\n"),
+			language: language,
+			isCode:   true,
+		})
+		idx = closing
+	}
+	flushProse()
+
+	return segments
+}
+
+type codeBlockKey struct {
+	code      string
+	language  string
+	width     int
+	formatter string
+	theme     string
+}
+
+var codeBlockCache = struct {
+	sync.Mutex
+	blocks map[codeBlockKey]string
+}{blocks: map[codeBlockKey]string{}}
+
+// Highlights code for the color profile of the session, lines wider than
+// width are cut off instead of wrapped so indentation stays readable
+func renderCodeBlock(code string, language string, width int, styles *styles.ClientStyles) string {
+	key := codeBlockKey{code, language, width, styles.CodeFormatter, styles.CodeTheme}
+	codeBlockCache.Lock()
+	block, ok := codeBlockCache.blocks[key]
+	codeBlockCache.Unlock()
+	if ok {
+		return block
+	}
+
+	lineWidth := max(width-styles.CodeBlock.GetHorizontalFrameSize(), 1)
+	lines := highlightCode(expandTabs(code), language, styles)
+	for idx, line := range lines {
+		lines[idx] = ansi.Truncate(line, lineWidth, "…")
+	}
+	block = styles.CodeBlock.Render(strings.Join(lines, "\n"))
+
+	codeBlockCache.Lock()
+	if len(codeBlockCache.blocks) >= maxCachedCodeBlocks {
+		clear(codeBlockCache.blocks)
+	}
+	codeBlockCache.blocks[key] = block
+	codeBlockCache.Unlock()
+
+	return block
+}
+
+// Lines are formatted one by one, each carries its own escape sequences
+func highlightCode(code string, language string, styles *styles.ClientStyles) []string {
+	lines := strings.Split(code, "\n")
+	if styles.CodeFormatter == "" {
+		return lines
+	}
+
+	lexer := lexers.Get(language)
+	if lexer == nil {
+		lexer = lexers.Analyse(code)
+	}
+	if lexer == nil {
+		return lines
+	}
+	iterator, err := chroma.Coalesce(lexer).Tokenise(nil, code)
+	if err != nil {
+		return lines
+	}
+
+	formatter := formatters.Get(styles.CodeFormatter)
+	theme := chromastyles.Get(styles.CodeTheme)
+	highlighted := []string{}
+	for _, lineTokens := range chroma.SplitTokensIntoLines(iterator.Tokens()) {
+		for idx := range lineTokens {
+			lineTokens[idx].Value = strings.TrimSuffix(lineTokens[idx].Value, "\n")
+		}
+		line := strings.Builder{}
+		if err := formatter.Format(&line, theme, chroma.Literator(lineTokens...)); err != nil {
+			return lines
+		}
+		highlighted = append(highlighted, line.String())
+	}
+	if len(highlighted) < len(lines) {
+		return lines
+	}
+	// Lexers add a trailing newline, the split leaves an extra line for it
+	return highlighted[:len(lines)]
+}
+
+func expandTabs(code string) string {
+	if !strings.Contains(code, "\t") {
+		return code
+	}
+
+	expanded := strings.Builder{}
+	column := 0
+	for _, r := range code {
+		switch r {
+		case '\t':
+			spaces := tabWidth - column%tabWidth
+			expanded.WriteString(strings.Repeat(" ", spaces))
+			column += spaces
+		case '\n':
+			expanded.WriteRune(r)
+			column = 0
+		default:
+			expanded.WriteRune(r)
+			column++
+		}
+	}
+	return expanded.String()
+}
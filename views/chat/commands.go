@@ -1,6 +1,9 @@
 package chat
 
-import tea "github.com/charmbracelet/bubbletea"
+import (
+	"github.com/NaiKiDEV/ssh-chat/internal/search"
+	tea "github.com/charmbracelet/bubbletea"
+)
 
 type MessageSentMsg struct {
 	Message string
@@ -8,6 +11,17 @@ type MessageSentMsg struct {
 
 type LeaveChatMsg struct{}
 
+// A room picked from the sidebar
+type RoomSelectedMsg struct {
+	RoomId string
+}
+
+type SearchResultSelectedMsg struct {
+	Result search.Result
+	// Words to highlight in the message
+	Terms []string
+}
+
 func createMessageSentCmd(message string) tea.Cmd {
 	return func() tea.Msg {
 		return MessageSentMsg{Message: message}
@@ -19,3 +33,15 @@ func createLeaveChatCmd() tea.Cmd {
 		return LeaveChatMsg{}
 	}
 }
+
+func createSearchResultSelectedCmd(result search.Result, terms []string) tea.Cmd {
+	return func() tea.Msg {
+		return SearchResultSelectedMsg{Result: result, Terms: terms}
+	}
+}
+
+func createRoomSelectedCmd(roomId string) tea.Cmd {
+	return func() tea.Msg {
+		return RoomSelectedMsg{RoomId: roomId}
+	}
+}
@@ -0,0 +1,197 @@
+package chat
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/NaiKiDEV/ssh-chat/internal/plugin"
+	"github.com/NaiKiDEV/ssh-chat/internal/styles"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textarea"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const maxCompletionCandidates = 5
+
+type completionCandidate struct {
+	text string
+	// Shown next to the candidate, e.g. what a command does
+	detail string
+}
+
+// Tab completion of @users, #rooms and /commands. The first candidate replaces
+// the word right away, the others are listed above the input while cycling.
+type tabCompletion struct {
+	// Word typed before completing, restored when the completion is undone
+	word       string
+	candidates []completionCandidate
+	selected   int
+}
+
+func (t tabCompletion) open() bool {
+	return len(t.candidates) > 0
+}
+
+// Rooms completed after #
+func (c ChatState) SetRooms(roomIds []string) ChatState {
+	c.rooms = roomIds
+	return c
+}
+
+// Commands completed after a leading /
+func (c ChatState) SetCommands(commands []plugin.Command) ChatState {
+	c.commands = commands
+	return c
+}
+
+// Word typed right before the cursor and whether it starts the message
+func wordBeforeCursor(input textarea.Model) (string, bool) {
+	lines := strings.Split(input.Value(), "\n")
+	row := input.Line()
+	if row >= len(lines) {
+		return "", false
+	}
+	line := []rune(lines[row])
+	info := input.LineInfo()
+	col := min(info.StartColumn+info.ColumnOffset, len(line))
+
+	start := col
+	for start > 0 && !unicode.IsSpace(line[start-1]) {
+		start--
+	}
+	return string(line[start:col]), row == 0 && start == 0
+}
+
+func (c ChatState) completionCandidates(word string, startsMessage bool) []completionCandidate {
+	if word == "" {
+		return nil
+	}
+	prefix := strings.ToLower(word[1:])
+	candidates := []completionCandidate{}
+	switch {
+	case word[0] == '@':
+		users := slices.Clone(c.activeUsers)
+		slices.Sort(users)
+		for _, user := range users {
+			if user != c.userName && strings.HasPrefix(strings.ToLower(user), prefix) {
+				candidates = append(candidates, completionCandidate{text: "@" + user})
+			}
+		}
+	case word[0] == '#':
+		for _, roomId := range c.rooms {
+			if strings.HasPrefix(strings.ToLower(roomId), prefix) {
+				candidates = append(candidates, completionCandidate{text: "#" + roomId})
+			}
+		}
+	// Slashes anywhere else are paths and fractions
+	case word[0] == '/' && startsMessage:
+		for _, command := range c.commands {
+			if strings.HasPrefix(strings.ToLower(command.Name), prefix) {
+				candidates = append(candidates, completionCandidate{text: "/" + command.Name, detail: command.Description})
+			}
+		}
+	}
+	return candidates
+}
+
+// Completes the word before the cursor, a single candidate is taken as is
+func (c ChatState) startCompletion(backwards bool) ChatState {
+	word, startsMessage := wordBeforeCursor(c.chatInput)
+	candidates := c.completionCandidates(word, startsMessage)
+	switch len(candidates) {
+	case 0:
+		return c
+	case 1:
+		return c.replaceBeforeCursor(word, candidates[0].text+" ")
+	}
+
+	c.completion = tabCompletion{word: word, candidates: candidates}
+	if backwards {
+		c.completion.selected = len(candidates) - 1
+	}
+	return c.replaceBeforeCursor(word, candidates[c.completion.selected].text)
+}
+
+// Keys taken over while candidates are listed, any other key keeps the
+// current candidate and is handled as usual
+func (c ChatState) updateCompletionKeys(msg tea.KeyMsg) (ChatState, bool) {
+	km := c.keyMap.Completion
+	count := len(c.completion.candidates)
+	current := c.completion.candidates[c.completion.selected].text
+	switch {
+	case key.Matches(msg, km.Next):
+		c.completion.selected = (c.completion.selected + 1) % count
+		c = c.replaceBeforeCursor(current, c.completion.candidates[c.completion.selected].text)
+	case key.Matches(msg, km.Previous):
+		c.completion.selected = (c.completion.selected - 1 + count) % count
+		c = c.replaceBeforeCursor(current, c.completion.candidates[c.completion.selected].text)
+	case key.Matches(msg, km.Accept):
+		c.chatInput.InsertString(" ")
+		c.completion = tabCompletion{}
+	case key.Matches(msg, km.Cancel):
+		c = c.replaceBeforeCursor(current, c.completion.word)
+		c.completion = tabCompletion{}
+	default:
+		c.completion = tabCompletion{}
+		return c, false
+	}
+	return c, true
+}
+
+// Swaps text typed right before the cursor for replacement
+func (c ChatState) replaceBeforeCursor(text string, replacement string) ChatState {
+	for range len([]rune(text)) {
+		c.chatInput, _ = c.chatInput.Update(tea.KeyMsg{Type: tea.KeyBackspace})
+	}
+	c.chatInput.InsertString(replacement)
+	return c
+}
+
+func renderTabCompletion(completion tabCompletion, width int, styles *styles.ClientStyles) string {
+	visible := min(len(completion.candidates), maxCompletionCandidates)
+	start := clamp(completion.selected-visible+1, 0, len(completion.candidates)-visible)
+
+	lines := []string{}
+	for idx := start; idx < start+visible; idx++ {
+		candidate := completion.candidates[idx]
+		marker := "  "
+		style := styles.RegularTxt.Foreground(styles.GreyColor)
+		if idx == completion.selected {
+			marker = styles.BoldRegularTxt.Foreground(styles.PrimaryColor).Render("› ")
+			style = styles.BoldRegularTxt.Foreground(styles.PrimaryColor)
+		}
+		line := marker + style.Render(candidate.text)
+		if candidate.detail != "" {
+			line += "  " + styles.RegularTxt.Foreground(styles.MutedColor).Render(candidate.detail)
+		}
+		lines = append(lines, line)
+	}
+	return lipgloss.NewStyle().Width(width).MaxWidth(width).Padding(0, containerXPadding).Render(strings.Join(lines, "\n"))
+}
+
+// Emoji or tab completion candidates shown right above the input
+func (c ChatState) renderSuggestions(width int) string {
+	switch {
+	case c.activeInputId != chatInputId:
+		return ""
+	case c.emoji.open():
+		return renderEmojiCompletion(c.emoji, width, c.clientStyles)
+	case c.completion.open():
+		return renderTabCompletion(c.completion, width, c.clientStyles)
+	}
+	return ""
+}
+
+func (c ChatState) suggestionRows() int {
+	switch {
+	case c.activeInputId != chatInputId:
+		return 0
+	case c.emoji.open():
+		return len(c.emoji.matches)
+	case c.completion.open():
+		return min(len(c.completion.candidates), maxCompletionCandidates)
+	}
+	return 0
+}
@@ -0,0 +1,104 @@
+package chat
+
+import (
+	"strings"
+
+	"github.com/NaiKiDEV/ssh-chat/internal/emoji"
+	"github.com/NaiKiDEV/ssh-chat/internal/styles"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textarea"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const maxEmojiSuggestions = 5
+
+// Suggestions for the :shortcode being typed, shown above the input
+type emojiCompletion struct {
+	query    string
+	matches  []emoji.Match
+	selected int
+	// Query the popup was closed for with esc, it stays closed until it changes
+	dismissed string
+}
+
+func (e emojiCompletion) open() bool {
+	return len(e.matches) > 0
+}
+
+// Shortcode typed right before the cursor, without the leading colon
+func shortcodeQuery(input textarea.Model) (string, bool) {
+	lines := strings.Split(input.Value(), "\n")
+	row := input.Line()
+	if row >= len(lines) {
+		return "", false
+	}
+	line := []rune(lines[row])
+	info := input.LineInfo()
+	col := min(info.StartColumn+info.ColumnOffset, len(line))
+
+	start := col
+	for start > 0 && emoji.IsShortcodeRune(line[start-1]) {
+		start--
+	}
+	// Colons inside words are times and URLs, not shortcodes
+	if start == col || start == 0 || line[start-1] != ':' {
+		return "", false
+	}
+	if start > 1 && !strings.ContainsRune(" \t([", line[start-2]) {
+		return "", false
+	}
+	return string(line[start:col]), true
+}
+
+func (c ChatState) updateEmojiCompletion() ChatState {
+	query, ok := shortcodeQuery(c.chatInput)
+	switch {
+	case !ok || c.activeInputId != chatInputId:
+		c.emoji = emojiCompletion{}
+	case query == c.emoji.dismissed:
+		c.emoji = emojiCompletion{dismissed: query}
+	case query != c.emoji.query:
+		c.emoji = emojiCompletion{query: query, matches: emoji.Complete(query, maxEmojiSuggestions)}
+	}
+	return c
+}
+
+// Keys taken over while suggestions are shown, false for the ones the input
+// should handle
+func (c ChatState) updateEmojiKeys(msg tea.KeyMsg) (ChatState, bool) {
+	switch {
+	case key.Matches(msg, c.keyMap.Emoji.Previous):
+		c.emoji.selected = (c.emoji.selected - 1 + len(c.emoji.matches)) % len(c.emoji.matches)
+	case key.Matches(msg, c.keyMap.Emoji.Next):
+		c.emoji.selected = (c.emoji.selected + 1) % len(c.emoji.matches)
+	case key.Matches(msg, c.keyMap.Emoji.Accept):
+		c = c.acceptEmoji()
+	case key.Matches(msg, c.keyMap.Emoji.Dismiss):
+		c.emoji = emojiCompletion{dismissed: c.emoji.query}
+	default:
+		return c, false
+	}
+	return c, true
+}
+
+// Replaces the typed :shortcode with the selected emoji
+func (c ChatState) acceptEmoji() ChatState {
+	c = c.replaceBeforeCursor(":"+c.emoji.query, c.emoji.matches[c.emoji.selected].Emoji)
+	c.emoji = emojiCompletion{}
+	return c
+}
+
+func renderEmojiCompletion(completion emojiCompletion, width int, styles *styles.ClientStyles) string {
+	lines := []string{}
+	for idx, match := range completion.matches {
+		marker := "  "
+		style := styles.RegularTxt.Foreground(styles.GreyColor)
+		if idx == completion.selected {
+			marker = styles.BoldRegularTxt.Foreground(styles.PrimaryColor).Render("› ")
+			style = styles.BoldRegularTxt.Foreground(styles.PrimaryColor)
+		}
+		lines = append(lines, marker+match.Emoji+" "+style.Render(":"+match.Shortcode+":"))
+	}
+	return lipgloss.NewStyle().Width(width).MaxWidth(width).Padding(0, containerXPadding).Render(strings.Join(lines, "\n"))
+}
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/charmbracelet/bubbles/textarea"
+	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 )
 
@@ -31,13 +32,31 @@ func createAreaInput(placeholder string, limit int, initialWidth int) textarea.M
 	ta.CharLimit = limit
 	ta.ShowLineNumbers = false
 
-	ta.MaxHeight = 2
+	// Longest message in lines, the visible height is set by layout
+	ta.MaxHeight = maxComposerLines
 	ta.FocusedStyle.CursorLine = lipgloss.NewStyle()
 
 	ta.Prompt = ""
 
-	ta.SetHeight(2)
+	ta.SetHeight(minComposerHeight)
 	ta.SetWidth(initialWidth)
 
 	return ta
 }
+
+// Textareas keep their scroll offset when resized. Scrolling all the way up
+// lets them show as much as fits, the update after keeps the cursor in view.
+func resizeAreaInput(ta textarea.Model, height int) textarea.Model {
+	if height == ta.Height() {
+		return ta
+	}
+	grows := height > ta.Height()
+	ta.SetHeight(height)
+	if grows {
+		for range ta.LineCount() {
+			ta, _ = ta.Update(tea.MouseMsg{Action: tea.MouseActionPress, Button: tea.MouseButtonWheelUp})
+		}
+	}
+	ta, _ = ta.Update(nil)
+	return ta
+}
@@ -0,0 +1,65 @@
+package chat
+
+import (
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Sent messages recalled with up and down
+type inputHistory struct {
+	entries []string
+	// Entry shown in the input, len(entries) while writing a new message
+	position int
+	// What was typed before browsing the history, restored past the newest
+	pending string
+}
+
+// Messages sent before, oldest first
+func (c ChatState) SetHistory(entries []string) ChatState {
+	c.history = inputHistory{entries: entries, position: len(entries)}
+	return c
+}
+
+// Draft is what is typed but not sent yet
+func (c ChatState) Draft() string {
+	return c.chatInput.Value()
+}
+
+func (c ChatState) SetDraft(draft string) ChatState {
+	c.chatInput.SetValue(draft)
+	c.history.position = len(c.history.entries)
+	c.emoji = emojiCompletion{}
+	c.completion = tabCompletion{}
+	return c.layout()
+}
+
+// Going back in the history works on the first row of the input and forward
+// on the last row, anywhere else the keys move the cursor
+func (c ChatState) recallHistory(msg tea.KeyMsg) (ChatState, bool) {
+	info := c.chatInput.LineInfo()
+	switch {
+	case key.Matches(msg, c.keyMap.Chat.HistoryBack):
+		if c.chatInput.Line() > 0 || info.RowOffset > 0 || c.history.position == 0 {
+			return c, false
+		}
+		if c.history.position == len(c.history.entries) {
+			c.history.pending = c.chatInput.Value()
+		}
+		c.history.position--
+		c.chatInput.SetValue(c.history.entries[c.history.position])
+	case key.Matches(msg, c.keyMap.Chat.HistoryNext):
+		if c.chatInput.Line() < c.chatInput.LineCount()-1 || info.RowOffset < info.Height-1 ||
+			c.history.position == len(c.history.entries) {
+			return c, false
+		}
+		c.history.position++
+		if c.history.position == len(c.history.entries) {
+			c.chatInput.SetValue(c.history.pending)
+		} else {
+			c.chatInput.SetValue(c.history.entries[c.history.position])
+		}
+	default:
+		return c, false
+	}
+	return c, true
+}
@@ -0,0 +1,61 @@
+package chat
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+var (
+	linkPattern = regexp.MustCompile(`https?://[^\s<>"]+`)
+	// OSC 8 sequences as written by ansi.SetHyperlink
+	hyperlinkPattern = regexp.MustCompile("\x1b]8;[^;\x07]*;([^\x07]*)\x07")
+)
+
+// Byte ranges of the URLs in line, punctuation closing a sentence or an
+// unbalanced bracket is left out
+func findLinks(line string) [][2]int {
+	links := [][2]int{}
+	for _, span := range linkPattern.FindAllStringIndex(line, -1) {
+		end := span[1]
+		for end > span[0] {
+			last := line[end-1]
+			if strings.IndexByte(".,:;!?'", last) != -1 ||
+				(last == ')' && strings.Count(line[span[0]:end], "(") < strings.Count(line[span[0]:end], ")")) ||
+				(last == ']' && strings.Count(line[span[0]:end], "[") < strings.Count(line[span[0]:end], "]")) {
+				end--
+				continue
+			}
+			break
+		}
+		if end > span[0] {
+			links = append(links, [2]int{span[0], end})
+		}
+	}
+	return links
+}
+
+// Wrapping can leave a hyperlink open across lines, which would also link the
+// padding and borders drawn around them. Each line gets its own sequences.
+func closeHyperlinksPerLine(text string) string {
+	if !strings.Contains(text, "\x1b]8;") {
+		return text
+	}
+
+	lines := strings.Split(text, "\n")
+	openLink := ""
+	for idx, line := range lines {
+		if openLink != "" {
+			line = ansi.SetHyperlink(openLink) + line
+		}
+		for _, match := range hyperlinkPattern.FindAllStringSubmatch(line, -1) {
+			openLink = match[1]
+		}
+		if openLink != "" {
+			line += ansi.ResetHyperlink()
+		}
+		lines[idx] = line
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,79 @@
+package chat
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+func TestFindLinks(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want []string
+	}{
+		{"plain", "see https://x/y for more", []string{"https://x/y"}},
+		{"wrapped in parentheses", "(https://x/y)", []string{"https://x/y"}},
+		{"balanced parentheses", "https://x/a_(b)", []string{"https://x/a_(b)"}},
+		{"balanced parentheses in parentheses", "(https://x/a_(b))", []string{"https://x/a_(b)"}},
+		{"sentence end", "read https://x/y.", []string{"https://x/y"}},
+		{"trailing punctuation", "really https://x/y?!", []string{"https://x/y"}},
+		{"wrapped in brackets", "[https://x/y]", []string{"https://x/y"}},
+		{"query string", "https://x/y?a=1&b=2, done", []string{"https://x/y?a=1&b=2"}},
+		{"several", "http://a/1 and https://b/2", []string{"http://a/1", "https://b/2"}},
+		{"no link", "nothing to see here.", nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got []string
+			for _, span := range findLinks(test.line) {
+				got = append(got, test.line[span[0]:span[1]])
+			}
+			if !slices.Equal(got, test.want) {
+				t.Errorf("findLinks(%q) = %q, want %q", test.line, got, test.want)
+			}
+		})
+	}
+}
+
+func TestCloseHyperlinksPerLine(t *testing.T) {
+	url := "https://x/long/path"
+	open, reset := ansi.SetHyperlink(url), ansi.ResetHyperlink()
+
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{
+			name: "no links",
+			text: "plain\ntext",
+			want: "plain\ntext",
+		},
+		{
+			name: "link within a line",
+			text: "see " + open + url + reset + " now\nnext",
+			want: "see " + open + url + reset + " now\nnext",
+		},
+		{
+			name: "link wrapped across lines",
+			text: open + "https://x/\nlong/path" + reset + " after",
+			want: open + "https://x/" + reset + "\n" + open + "long/path" + reset + " after",
+		},
+		{
+			name: "link wrapped across three lines",
+			text: "see " + open + "https://\nx/long\n/path" + reset,
+			want: "see " + open + "https://" + reset + "\n" + open + "x/long" + reset + "\n" + open + "/path" + reset,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := closeHyperlinksPerLine(test.text); got != test.want {
+				t.Errorf("closeHyperlinksPerLine(%q)\n got %q\nwant %q", test.text, got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package chat
+
+import (
+	"github.com/NaiKiDEV/ssh-chat/internal/terminal"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Rows above the input label and the buttons within the form
+const (
+	formPaddingTop        = 2
+	buttonGroupPaddingTop = 1
+	buttonGroupPaddingX   = 1
+)
+
+// Parts of the layout that react to clicks, in screen cells
+type chatAreas struct {
+	input       terminal.Area
+	sendButton  terminal.Area
+	leaveButton terminal.Area
+	// Last row of the messages while it shows the new messages badge
+	badge terminal.Area
+}
+
+func (c ChatState) areas() chatAreas {
+	formY := c.headerHeight() + c.contentHeight
+	buttonsX := containerXPadding + inputBoxWidth(c.width) + formGap + buttonGroupPaddingX
+	buttonsY := formY + formPaddingTop + buttonGroupPaddingTop
+	sendWidth := lipgloss.Width(renderButton("send", false, c.clientStyles))
+	leaveWidth := lipgloss.Width(renderButton("leave", false, c.clientStyles))
+
+	areas := chatAreas{
+		input:       terminal.Area{X: containerXPadding, Y: formY + formPaddingTop, Width: inputBoxWidth(c.width), Height: c.chatInput.Height() + 1},
+		sendButton:  terminal.Area{X: buttonsX, Y: buttonsY, Width: sendWidth, Height: 1},
+		leaveButton: terminal.Area{X: buttonsX + sendWidth + buttonGap, Y: buttonsY, Width: leaveWidth, Height: 1},
+	}
+	if c.unseen > 0 {
+		areas.badge = terminal.Area{X: 0, Y: formY - c.suggestionRows() - 1, Width: c.chatViewport.Width, Height: 1}
+	}
+	return areas
+}
+
+// Presses the button, focuses the input or picks the user or room at x, y
+func (c ChatState) click(x int, y int) (ChatState, tea.Cmd) {
+	if c.tooSmall() || c.showHelp || c.chatInputExpanded || c.search.open {
+		return c, nil
+	}
+
+	areas := c.areas()
+	switch {
+	case areas.sendButton.Contains(x, y):
+		return c.send()
+	case areas.leaveButton.Contains(x, y):
+		return c, createLeaveChatCmd()
+	case areas.input.Contains(x, y):
+		c.activeInputId = chatInputId
+		c.chatInput.Focus()
+		return c, nil
+	case areas.badge.Contains(x, y):
+		return c.jumpToBottom(), nil
+	}
+
+	if !c.sidebarVisible() {
+		return c, nil
+	}
+	for idx, line := range c.sidebarLines(c.activeUsers, c.clientStyles) {
+		if !c.sidebarLineArea(idx).Contains(x, y) {
+			continue
+		}
+		switch {
+		case line.user != "" && line.user != c.userName:
+			return c.mention(line.user), nil
+		case line.room != "" && line.room != c.roomId:
+			return c, createRoomSelectedCmd(line.room)
+		}
+	}
+	return c, nil
+}
+
+// Puts @user at the cursor of the focused input
+func (c ChatState) mention(user string) ChatState {
+	c.activeInputId = chatInputId
+	c.chatInput.Focus()
+	if word, _ := wordBeforeCursor(c.chatInput); word != "" {
+		c.chatInput.InsertString(" ")
+	}
+	c.chatInput.InsertString("@" + user + " ")
+	c.emoji = emojiCompletion{}
+	c.completion = tabCompletion{}
+	return c
+}
@@ -0,0 +1,94 @@
+package chat
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/NaiKiDEV/ssh-chat/internal/model"
+	"github.com/NaiKiDEV/ssh-chat/internal/search"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const plainLeaveCommand = "/leave"
+
+// Number of history messages announced when joining a room in plain mode
+const plainHistorySize = 20
+
+// Plain text rendering used by the accessible mode. Everything here has to
+// stay linear: no colors, borders or layout, one line per announcement.
+
+func FormatPlainMessage(msg model.Message) string {
+	lines := strings.Split(msg.PlainText(), "\n")
+	return fmt.Sprintf("%s (%s): %s", msg.DisplayName(), formatTime(msg.Timestamp), strings.Join(lines, "\n  "))
+}
+
+func FormatPlainEvent(event model.Event) string {
+	switch event.Kind {
+	case model.EventMessage:
+		return FormatPlainMessage(*event.Message)
+	case model.EventJoin:
+		return fmt.Sprintf("%s joined %s", event.Username, event.RoomId)
+	case model.EventLeave:
+		return fmt.Sprintf("%s left %s", event.Username, event.RoomId)
+	case model.EventTopic:
+		return fmt.Sprintf("%s changed the topic of %s to: %s", event.Username, event.RoomId, event.Topic)
+	}
+	return ""
+}
+
+// FormatPlainRoomIntro is announced once after joining a room, followed by
+// the most recent history.
+func FormatPlainRoomIntro(roomId string, messages []model.Message, activeUsers []string) string {
+	intro := strings.Builder{}
+	intro.WriteString(fmt.Sprintf("Joined room %s. ", roomId))
+	intro.WriteString(fmt.Sprintf("%d online: %s.\n", len(activeUsers), strings.Join(activeUsers, ", ")))
+	intro.WriteString("Type a message and press Enter to send, type /leave to leave the room.")
+
+	history := messages
+	if len(history) > plainHistorySize {
+		history = history[len(history)-plainHistorySize:]
+	}
+	if len(history) > 0 {
+		intro.WriteString(fmt.Sprintf("\nLast %d messages:", len(history)))
+		for _, msg := range history {
+			intro.WriteString("\n" + FormatPlainMessage(msg))
+		}
+	}
+	return intro.String()
+}
+
+func FormatPlainSearchResults(results []search.Result) string {
+	lines := []string{formatResultCount(len(results)) + ", newest first:"}
+	for _, result := range results {
+		lines = append(lines, fmt.Sprintf("[%s] %s", result.RoomId, FormatPlainMessage(result.Message)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (c ChatState) RenderPlain() string {
+	return fmt.Sprintf("[%s] %s: %s", c.roomId, c.userName, c.chatInput.Value())
+}
+
+func (c ChatState) updatePlain(msg tea.Msg) (ChatState, tea.Cmd) {
+	var cmd tea.Cmd
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		var recalled bool
+		if c, recalled = c.recallHistory(msg); recalled {
+			return c, nil
+		}
+		if msg.Type == tea.KeyEnter {
+			value := strings.TrimSpace(c.chatInput.Value())
+			c.chatInput.SetValue("")
+			switch value {
+			case "":
+				return c, nil
+			case plainLeaveCommand:
+				return c, createLeaveChatCmd()
+			}
+			return c, createMessageSentCmd(value)
+		}
+		c.chatInput, cmd = c.chatInput.Update(msg)
+	}
+	return c, cmd
+}
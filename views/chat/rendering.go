@@ -2,32 +2,115 @@ package chat
 
 import (
 	"fmt"
+	"slices"
 	"strings"
-	"time"
 
+	"github.com/NaiKiDEV/ssh-chat/internal/consts"
+	"github.com/NaiKiDEV/ssh-chat/internal/keymap"
 	"github.com/NaiKiDEV/ssh-chat/internal/model"
+	"github.com/NaiKiDEV/ssh-chat/internal/search"
 	"github.com/NaiKiDEV/ssh-chat/internal/styles"
+	"github.com/NaiKiDEV/ssh-chat/internal/terminal"
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/textarea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
 )
 
-func renderMessage(message string, userName string, isOwned bool, timestamp time.Time, styles *styles.ClientStyles) string {
+// highlight is only passed for the message jumped to from a search, width is
+// the one of the message view
+func renderMessage(msg model.Message, isOwned bool, highlight *messageHighlight, width int, styles *styles.ClientStyles) string {
 	container := lipgloss.NewStyle().Padding(0, 1, 1)
+	if highlight != nil {
+		container = container.BorderStyle(lipgloss.ThickBorder()).BorderLeft(true).BorderForeground(styles.PrimaryColor)
+	}
 
 	labelColor := styles.GreyColor
 	if isOwned {
 		labelColor = styles.PrimaryColor
 	}
 
-	styledLabel := styles.BoldRegularTxt.Foreground(labelColor).Render(userName)
-	styledMessage := styles.RegularTxt.Render(message)
-	styledTimestamp := styles.RegularTxt.Foreground(styles.MutedColor).Render(fmt.Sprintf(" (%s) ", formatTime(timestamp)))
+	styledLabel := styles.BoldRegularTxt.Foreground(labelColor).Render(msg.Username)
+	if msg.Integration != "" {
+		// Integrations get their own color and a badge naming the source
+		styledLabel = styles.BoldRegularTxt.Foreground(styles.IntegrationColor).Render(msg.Username) +
+			" " + styles.IntegrationBadge.Render(msg.Integration)
+	}
+	textWidth := max(width-container.GetHorizontalFrameSize(), 1)
+	styledSegments := []string{}
+	for _, segment := range splitCodeBlocks(msg.Text) {
+		if segment.isCode {
+			styledSegments = append(styledSegments, renderCodeBlock(segment.text, segment.language, textWidth, styles))
+			continue
+		}
+		var terms []string
+		if highlight != nil {
+			terms = highlight.terms
+		}
+		styledText := lipgloss.NewStyle().Width(textWidth).Render(renderText(segment.text, terms, styles))
+		styledSegments = append(styledSegments, closeHyperlinksPerLine(styledText))
+	}
+	styledMessage := lipgloss.JoinVertical(lipgloss.Top, styledSegments...)
+	styledTimestamp := styles.RegularTxt.Foreground(styles.MutedColor).Render(fmt.Sprintf(" (%s) ", formatTime(msg.Timestamp)))
+
+	if msg.Attachment != nil {
+		styledAttachment := styles.RegularTxt.Foreground(styles.MutedColor).Render(
+			fmt.Sprintf("[file] %s · %s", msg.Attachment.Summary(), msg.Attachment.Path))
+		styledMessage = lipgloss.JoinVertical(lipgloss.Top, styledMessage, styledAttachment)
+	}
 
 	messageCard := lipgloss.JoinVertical(lipgloss.Top, styledLabel+styledTimestamp, styledMessage)
 
 	return container.Render(messageCard)
 }
 
+// Renders text line by line with links styled and the words matching terms
+// highlighted, links are only clickable in terminals supporting OSC 8
+func renderText(text string, terms []string, styles *styles.ClientStyles) string {
+	lines := strings.Split(text, "\n")
+	for idx, line := range lines {
+		links := findLinks(line)
+		matches := search.MatchRanges(line, terms)
+
+		bounds := []int{0, len(line)}
+		for _, span := range append(slices.Clone(links), matches...) {
+			bounds = append(bounds, span[0], span[1])
+		}
+		slices.Sort(bounds)
+		bounds = slices.Compact(bounds)
+
+		styledLine := strings.Builder{}
+		for i := 0; i+1 < len(bounds); i++ {
+			start, end := bounds[i], bounds[i+1]
+			style := styles.RegularTxt
+			link := spanAt(links, start)
+			if link != nil {
+				style = styles.Link
+			}
+			if spanAt(matches, start) != nil {
+				style = styles.SearchHighlight
+			}
+
+			piece := style.Render(line[start:end])
+			if link != nil && styles.Hyperlinks {
+				piece = ansi.SetHyperlink(line[link[0]:link[1]]) + piece + ansi.ResetHyperlink()
+			}
+			styledLine.WriteString(piece)
+		}
+		lines[idx] = styledLine.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+func spanAt(spans [][2]int, offset int) *[2]int {
+	for idx := range spans {
+		if spans[idx][0] <= offset && offset < spans[idx][1] {
+			return &spans[idx]
+		}
+	}
+	return nil
+}
+
 func renderAreaInput(label string, ta textarea.Model, styles *styles.ClientStyles) string {
 	inputFocused := ta.Focused()
 	labelColor := styles.GreyColor
@@ -40,23 +123,153 @@ func renderAreaInput(label string, ta textarea.Model, styles *styles.ClientStyle
 	return input
 }
 
-func renderMessageView(loggedInUsername string, messages []model.Message, styles *styles.ClientStyles) string {
+func renderMessageView(loggedInUsername string, messages []model.Message, highlight *messageHighlight, width int, styles *styles.ClientStyles) string {
 	if messages == nil {
 		return ""
 	}
 
 	messageContent := strings.Builder{}
-	for _, msg := range messages {
-		messageContent.WriteString(renderMessage(msg.Text, msg.Username, msg.Username == loggedInUsername, msg.Timestamp, styles))
+	for idx, msg := range messages {
+		isOwned := msg.Username == loggedInUsername && msg.Integration == ""
+		var msgHighlight *messageHighlight
+		if highlight != nil && highlight.position == idx {
+			msgHighlight = highlight
+		}
+		messageContent.WriteString(renderMessage(msg, isOwned, msgHighlight, width, styles))
 		messageContent.WriteRune('\n')
 	}
 
 	return messageContent.String()
 }
 
+// Line of the message view the message at position starts on
+func messageLineOffset(loggedInUsername string, messages []model.Message, position int, width int, styles *styles.ClientStyles) int {
+	offset := 0
+	for _, msg := range messages[:clamp(position, 0, len(messages))] {
+		isOwned := msg.Username == loggedInUsername && msg.Integration == ""
+		offset += lipgloss.Height(renderMessage(msg, isOwned, nil, width, styles))
+	}
+	return offset
+}
+
+// Logo with the notice next to it, a single line on short terminals. Without
+// the sidebar the header names the room.
+func (c ChatState) renderHeader(onlineCount int, styles *styles.ClientStyles) string {
+	width := c.chatViewport.Width
+	summary := ""
+	if !c.sidebarVisible() && c.roomId != "" {
+		summary = fmt.Sprintf("#%s · %d online", c.roomId, onlineCount)
+	}
+	summaryStyle := styles.RegularTxt.Foreground(styles.MutedColor)
+
+	if !c.showLogo() {
+		title := styles.BoldRegularTxt.Foreground(styles.PrimaryColor).Padding(0, 1).Render("ssh-chat")
+		if summary != "" {
+			title += summaryStyle.Render(ansi.Truncate(summary, width-lipgloss.Width(title), "…"))
+		}
+		notice := renderNotice(c.notice, c.noticeIsError, width-lipgloss.Width(title), 1, styles)
+		return lipgloss.NewStyle().Width(width).Height(compactHeaderHeight).MaxHeight(compactHeaderHeight).Render(title + notice)
+	}
+
+	logo := lipgloss.NewStyle().
+		Foreground(styles.PrimaryColor).
+		Padding(0, 1, 1).
+		Render(consts.LOGO_NO_MARGIN)
+	sideWidth := width - lipgloss.Width(logo)
+	noticeHeight := lipgloss.Height(consts.LOGO_NO_MARGIN)
+	side := []string{}
+	if summary != "" {
+		side = append(side, summaryStyle.Padding(0, 1).Render(ansi.Truncate(summary, sideWidth-2, "…")))
+		noticeHeight--
+	}
+	if notice := renderNotice(c.notice, c.noticeIsError, sideWidth, noticeHeight, styles); notice != "" {
+		side = append(side, notice)
+	}
+
+	return lipgloss.NewStyle().
+		Width(width).
+		Render(lipgloss.JoinHorizontal(lipgloss.Center, logo, lipgloss.JoinVertical(lipgloss.Left, side...)))
+}
+
+// Takes the whole screen for writing long messages
+func renderExpandedComposer(c ChatState, terminalState *terminal.TerminalState, styles *styles.ClientStyles) string {
+	label := styles.BoldRegularTxt.Foreground(styles.PrimaryColor).Render(c.userName + ": ")
+	km := c.keyMap.Chat
+	hint := styles.RegularTxt.Foreground(styles.MutedColor).Render(formatHint(
+		keyHint{km.Send, "send"}, keyHint{km.Newline, "new line"}, keyHint{km.Blur, "back"}))
+	parts := []string{label + hint, "", c.chatInput.View()}
+	if suggestions := c.renderSuggestions(terminalState.Width - containerXPadding*2); suggestions != "" {
+		parts = append(parts, suggestions)
+	}
+	return lipgloss.NewStyle().
+		Width(terminalState.Width).
+		Height(terminalState.Height).
+		MaxHeight(terminalState.Height).
+		Padding(0, containerXPadding).
+		Render(strings.Join(parts, "\n"))
+}
+
+// Bindings whose first key is named in a hint line
+type keyHint struct {
+	binding key.Binding
+	label   string
+}
+
+// Hint line such as "enter send · esc back", disabled bindings are left out
+func formatHint(hints ...keyHint) string {
+	parts := []string{}
+	for _, hint := range hints {
+		if k := keymap.FirstKey(hint.binding); k != "" {
+			parts = append(parts, k+" "+hint.label)
+		}
+	}
+	return strings.Join(parts, " · ")
+}
+
+// Every binding of the chat in place of the whole layout, scrolled by offset
+// lines when taller than the terminal
+func renderHelp(c ChatState, terminalState *terminal.TerminalState, styles *styles.ClientStyles) string {
+	km := c.keyMap
+	width := terminalState.Width - containerXPadding*2
+	lines := helpLines(km, width, styles)
+	visible := max(terminalState.Height-helpHeaderHeight, 1)
+	offset := clamp(c.helpOffset, 0, max(len(lines)-visible, 0))
+
+	hints := []keyHint{{km.Global.CloseHelp, "close"}}
+	if len(lines) > visible {
+		hints = append(hints, keyHint{km.Chat.ScrollDown, "scroll"})
+	}
+	title := styles.BoldRegularTxt.Foreground(styles.PrimaryColor).Render("Key bindings")
+	hint := styles.RegularTxt.Foreground(styles.MutedColor).Render(" " + formatHint(hints...))
+	parts := append([]string{lipgloss.NewStyle().MaxWidth(width).Render(title + hint), ""}, lines[offset:min(offset+visible, len(lines))]...)
+	return lipgloss.NewStyle().
+		Width(terminalState.Width).
+		Height(terminalState.Height).
+		MaxHeight(terminalState.Height).
+		Padding(0, containerXPadding).
+		Render(strings.Join(parts, "\n"))
+}
+
+func helpLines(km *keymap.KeyMap, width int, styles *styles.ClientStyles) []string {
+	return strings.Split(keymap.RenderHelp(km.ChatHelp(), width, styles), "\n")
+}
+
 func renderButton(label string, active bool, styles *styles.ClientStyles) string {
 	if active {
 		return styles.ActiveButton.Bold(true).UnsetBackground().Foreground(styles.PrimaryColor).Render(label)
 	}
 	return styles.Button.Bold(true).UnsetBackground().Foreground(styles.MutedColor).Render(label)
 }
+
+// Wraps the notice within width, anything past height lines is cut off
+func renderNotice(notice string, isError bool, width int, height int, styles *styles.ClientStyles) string {
+	if notice == "" || width <= 2 || height <= 0 {
+		return ""
+	}
+
+	color := styles.MutedColor
+	if isError {
+		color = styles.ErrorColor
+	}
+	return styles.RegularTxt.Foreground(color).Width(width).MaxHeight(height).Padding(0, 1).Render(notice)
+}
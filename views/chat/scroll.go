@@ -0,0 +1,84 @@
+package chat
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/NaiKiDEV/ssh-chat/internal/styles"
+	"github.com/charmbracelet/bubbles/viewport"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// Puts the messages into the viewport, kept at the bottom while following
+func (c ChatState) syncViewport() ChatState {
+	c.chatViewport.SetContent(renderMessageView(c.userName, c.messages, c.highlight, c.chatViewport.Width, c.clientStyles))
+	if c.following {
+		c.chatViewport.GotoBottom()
+	}
+	return c
+}
+
+// New messages are followed again once scrolled back to the bottom
+func (c ChatState) updateFollowing() ChatState {
+	c.following = c.chatViewport.AtBottom()
+	if c.following {
+		c.unseen = 0
+	}
+	return c
+}
+
+func (c ChatState) jumpToBottom() ChatState {
+	c.following = true
+	c.unseen = 0
+	return c.syncViewport()
+}
+
+func formatNewMessages(count int) string {
+	if count == 1 {
+		return "1 new message ↓"
+	}
+	return fmt.Sprintf("%d new messages ↓", count)
+}
+
+// Overlays the viewport with a scrollbar on its last column and the count of
+// messages below the visible ones on its last row
+func renderScrollState(vp viewport.Model, view string, unseen int, jumpKey string, styles *styles.ClientStyles) string {
+	lines := strings.Split(view, "\n")
+	if len(lines) == 0 || vp.Width < 2 {
+		return view
+	}
+
+	width := vp.Width
+	bars := make([]string, len(lines))
+	total := vp.TotalLineCount()
+	if total > vp.Height && vp.Height > 0 {
+		width--
+		thumbSize := max(vp.Height*vp.Height/total, 1)
+		thumbStart := vp.YOffset * (vp.Height - thumbSize) / max(total-vp.Height, 1)
+		track := styles.RegularTxt.Foreground(styles.MutedColor).Render("│")
+		thumb := styles.RegularTxt.Foreground(styles.PrimaryColor).Render("┃")
+		for idx := range bars {
+			bars[idx] = track
+			if idx >= thumbStart && idx < thumbStart+thumbSize {
+				bars[idx] = thumb
+			}
+		}
+	}
+
+	for idx := range lines {
+		lines[idx] = ansi.Truncate(lines[idx], width, "")
+	}
+	if unseen > 0 {
+		label := formatNewMessages(unseen)
+		if jumpKey != "" {
+			label += " · " + jumpKey
+		}
+		badge := styles.ActiveButton.Padding(0, 1).Render(label)
+		lines[len(lines)-1] = lipgloss.PlaceHorizontal(width, lipgloss.Center, badge)
+	}
+	for idx := range lines {
+		lines[idx] += bars[idx]
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,115 @@
+package chat
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/NaiKiDEV/ssh-chat/internal/keymap"
+	"github.com/NaiKiDEV/ssh-chat/internal/search"
+	"github.com/NaiKiDEV/ssh-chat/internal/styles"
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Results of the last /search, listed in place of the messages while open
+type searchState struct {
+	open     bool
+	query    string
+	terms    []string
+	results  []search.Result
+	selected int
+}
+
+// Message jumped to from the search results
+type messageHighlight struct {
+	position int
+	terms    []string
+}
+
+func (c ChatState) ShowSearchResults(query string, terms []string, results []search.Result) ChatState {
+	c.search = searchState{
+		open:    true,
+		query:   query,
+		terms:   terms,
+		results: results,
+	}
+	return c
+}
+
+// Scrolls the message at position of the current room to the top of the
+// viewport and highlights terms in it until the next message is sent
+func (c ChatState) JumpToMessage(position int, terms []string) ChatState {
+	c.highlight = &messageHighlight{position: position, terms: terms}
+	c.following = false
+	c = c.syncViewport()
+	c.chatViewport.SetYOffset(messageLineOffset(c.userName, c.messages, position, c.chatViewport.Width, c.clientStyles))
+	return c.updateFollowing()
+}
+
+func (c ChatState) updateSearch(msg tea.KeyMsg) (ChatState, tea.Cmd) {
+	km := c.keyMap.Search
+	lastResult := len(c.search.results) - 1
+	switch {
+	case key.Matches(msg, km.Up):
+		c.search.selected = clamp(c.search.selected-1, 0, lastResult)
+	case key.Matches(msg, km.Down):
+		c.search.selected = clamp(c.search.selected+1, 0, lastResult)
+	case key.Matches(msg, km.PageUp):
+		c.search.selected = clamp(c.search.selected-c.chatViewport.Height/2, 0, lastResult)
+	case key.Matches(msg, km.PageDown):
+		c.search.selected = clamp(c.search.selected+c.chatViewport.Height/2, 0, lastResult)
+	case key.Matches(msg, km.First):
+		c.search.selected = 0
+	case key.Matches(msg, km.Last):
+		c.search.selected = lastResult
+	case key.Matches(msg, km.Select):
+		c.search.open = false
+		return c, createSearchResultSelectedCmd(c.search.results[c.search.selected], c.search.terms)
+	case key.Matches(msg, km.Close):
+		c.search.open = false
+	}
+	return c, nil
+}
+
+// One line per result with the selected one kept in view, takes the place of
+// the messages viewport
+func renderSearchResults(state searchState, km keymap.SearchKeys, width int, height int, styles *styles.ClientStyles) string {
+	title := styles.BoldRegularTxt.Render(fmt.Sprintf("%s for %q", formatResultCount(len(state.results)), state.query))
+	hint := styles.RegularTxt.Foreground(styles.MutedColor).Render(" " + formatHint(
+		keyHint{km.Up, "previous"}, keyHint{km.Down, "next"}, keyHint{km.Select, "jump"}, keyHint{km.Close, "close"}))
+	lines := []string{lipgloss.NewStyle().MaxWidth(width).Render(title + hint), ""}
+
+	visible := max(height-len(lines), 1)
+	start := clamp(state.selected-visible+1, 0, len(state.results)-visible)
+	end := min(start+visible, len(state.results))
+	for idx := start; idx < end; idx++ {
+		lines = append(lines, renderSearchResult(state.results[idx], state.terms, idx == state.selected, width, styles))
+	}
+
+	return lipgloss.NewStyle().Width(width).Height(height).MaxHeight(height).Render(strings.Join(lines, "\n"))
+}
+
+func renderSearchResult(result search.Result, terms []string, selected bool, width int, styles *styles.ClientStyles) string {
+	labelColor := styles.GreyColor
+	marker := "  "
+	if selected {
+		labelColor = styles.PrimaryColor
+		marker = styles.BoldRegularTxt.Foreground(styles.PrimaryColor).Render("› ")
+	}
+
+	room := styles.RegularTxt.Foreground(labelColor).Render("#" + result.RoomId)
+	author := styles.BoldRegularTxt.Foreground(labelColor).Render(result.Message.DisplayName())
+	timestamp := styles.RegularTxt.Foreground(styles.MutedColor).Render(result.Message.Timestamp.UTC().Format("Jan 02 15:04"))
+	text := renderText(strings.Join(strings.Fields(result.Message.Text), " "), terms, styles)
+
+	line := marker + room + " " + author + " " + timestamp + "  " + text
+	return lipgloss.NewStyle().MaxWidth(width).Render(line)
+}
+
+func formatResultCount(count int) string {
+	if count == 1 {
+		return "1 result"
+	}
+	return fmt.Sprintf("%d results", count)
+}
@@ -0,0 +1,111 @@
+package chat
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/NaiKiDEV/ssh-chat/internal/styles"
+	"github.com/NaiKiDEV/ssh-chat/internal/terminal"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// A line of the sidebar, user or room is set on the lines naming one so
+// clicking them does something
+type sidebarLine struct {
+	text string
+	user string
+	room string
+}
+
+// Room, online users and the rooms to switch to. Users past the height of the
+// sidebar are summed up so the rooms stay visible.
+func (c ChatState) sidebarLines(activeUsers []string, styles *styles.ClientStyles) []sidebarLine {
+	textWidth := onlineUsersContainerWidth
+	label := func(text string) sidebarLine {
+		return sidebarLine{text: lipgloss.NewStyle().Bold(true).Render(text)}
+	}
+	blank := sidebarLine{}
+
+	lines := []sidebarLine{}
+	if c.roomId != "" {
+		roomLabel := styles.BoldRegularTxt.Render("Room:")
+		roomId := styles.RegularTxt.Foreground(styles.PrimaryColor).Underline(true).Render(ansi.Truncate(c.roomId, textWidth-lipgloss.Width(roomLabel)-1, "…"))
+		lines = append(lines, blank, blank, sidebarLine{text: roomLabel + " " + roomId})
+		if c.topic != "" {
+			topic := styles.RegularTxt.Foreground(styles.MutedColor).Width(textWidth).MaxHeight(2).Render(c.topic)
+			for _, text := range strings.Split(topic, "\n") {
+				lines = append(lines, sidebarLine{text: text})
+			}
+			lines = append(lines, blank)
+		} else {
+			lines = append(lines, blank, blank)
+		}
+	}
+	lines = append(lines, label(fmt.Sprintf("Online Count: %d", len(activeUsers))), blank)
+
+	roomLines := []sidebarLine{}
+	if len(c.rooms) > 0 {
+		roomLines = append(roomLines, label("Rooms:"), blank)
+		for _, roomId := range c.rooms {
+			color := styles.GreyColor
+			if roomId == c.roomId {
+				color = styles.PrimaryColor
+			}
+			text := styles.BoldRegularTxt.Foreground(color).Render(ansi.Truncate("#"+roomId, textWidth, "…"))
+			roomLines = append(roomLines, sidebarLine{text: text, room: roomId})
+		}
+	}
+
+	if len(activeUsers) > 0 {
+		lines = append(lines, label("Online Users:"), blank)
+		// Bottom padding, the gap after the users and the rooms
+		space := max(c.contentHeight+c.headerHeight()-1-len(lines)-1-len(roomLines), 1)
+		shown := activeUsers
+		if len(activeUsers) > space {
+			shown = activeUsers[:space-1]
+		}
+		for _, user := range shown {
+			labelColor := styles.GreyColor
+			if user == c.userName {
+				labelColor = styles.PrimaryColor
+			}
+			// Names are cut off by display width, emoji take two cells
+			text := styles.BoldRegularTxt.Foreground(labelColor).Render(ansi.Truncate(user, textWidth, "…"))
+			lines = append(lines, sidebarLine{text: text, user: user})
+		}
+		if hidden := len(activeUsers) - len(shown); hidden > 0 {
+			more := styles.RegularTxt.Foreground(styles.MutedColor).Render(fmt.Sprintf("+%d more", hidden))
+			lines = append(lines, sidebarLine{text: more})
+		}
+		lines = append(lines, blank)
+	}
+
+	return append(lines, roomLines...)
+}
+
+func (c ChatState) renderSidebar(activeUsers []string, styles *styles.ClientStyles) string {
+	texts := []string{}
+	for _, line := range c.sidebarLines(activeUsers, styles) {
+		texts = append(texts, line.text)
+	}
+	height := c.contentHeight + c.headerHeight()
+	return lipgloss.NewStyle().
+		Height(height).
+		MaxHeight(height).
+		Width(onlineUsersContainerWidth).
+		Padding(0, onlineUsersContainerPadding, 1).
+		BorderStyle(lipgloss.NormalBorder()).
+		BorderLeft(true).
+		Render(strings.Join(texts, "\n"))
+}
+
+// Where the line at idx of the sidebar is on the screen
+func (c ChatState) sidebarLineArea(idx int) terminal.Area {
+	return terminal.Area{
+		X:      c.chatViewport.Width + onlineUsersContainerBorder + onlineUsersContainerPadding,
+		Y:      idx,
+		Width:  onlineUsersContainerWidth,
+		Height: 1,
+	}
+}
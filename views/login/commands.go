@@ -6,8 +6,18 @@ type RoomJoinRequestedMsg struct {
 	RoomId string
 }
 
+type AccessibleModeToggledMsg struct {
+	Enabled bool
+}
+
 func createRoomJoinRequestCmd(roomId string) tea.Cmd {
 	return func() tea.Msg {
 		return RoomJoinRequestedMsg{RoomId: roomId}
 	}
 }
+
+func createAccessibleModeToggledCmd(enabled bool) tea.Cmd {
+	return func() tea.Msg {
+		return AccessibleModeToggledMsg{Enabled: enabled}
+	}
+}
@@ -2,10 +2,15 @@ package login
 
 import (
 	"fmt"
+	"math"
+	"slices"
+	"strings"
 
 	"github.com/NaiKiDEV/ssh-chat/internal/consts"
+	"github.com/NaiKiDEV/ssh-chat/internal/keymap"
 	"github.com/NaiKiDEV/ssh-chat/internal/styles"
 	"github.com/NaiKiDEV/ssh-chat/internal/terminal"
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
@@ -19,6 +24,7 @@ const (
 
 const (
 	roomInputId = iota
+	accessibleToggleId
 	buttonsId
 )
 
@@ -34,17 +40,36 @@ type LoginState struct {
 	activeButtonId int
 	roomTextInput  textinput.Model
 	formError      string
+	notice         string
+	noticeIsError  bool
+	motd           string
 
 	activeElementId int
 
-	userName string
+	userName   string
+	accessible bool
+
+	keyMap *keymap.KeyMap
+	// Key bindings listed in place of the dialog
+	showHelp bool
+
+	terminalState *terminal.TerminalState
+	clientStyles  *styles.ClientStyles
+}
+
+// Parts of the dialog that react to clicks, in screen cells
+type dialogAreas struct {
+	roomInput terminal.Area
+	toggle    terminal.Area
+	quit      terminal.Area
+	join      terminal.Area
 }
 
 type LoginSubmitMsg struct {
 	RoomId string
 }
 
-func NewLoginState(userName string) LoginState {
+func NewLoginState(userName string, ts *terminal.TerminalState, cs *styles.ClientStyles) LoginState {
 	roomTextInput := createTextInput("", 9)
 	roomTextInput.Focus()
 	return LoginState{
@@ -52,9 +77,17 @@ func NewLoginState(userName string) LoginState {
 		roomTextInput:   roomTextInput,
 		activeElementId: roomInputId,
 		userName:        userName,
+		keyMap:          keymap.Default(),
+		terminalState:   ts,
+		clientStyles:    cs,
 	}
 }
 
+func (l LoginState) SetKeyMap(km *keymap.KeyMap) LoginState {
+	l.keyMap = km
+	return l
+}
+
 func (l LoginState) Init() tea.Cmd {
 	return textinput.Blink
 }
@@ -67,6 +100,24 @@ func (l LoginState) Reset() LoginState {
 	return l
 }
 
+func (l LoginState) SetAccessible(accessible bool) LoginState {
+	l.accessible = accessible
+	return l
+}
+
+// Server wide notice shown above the form, an empty notice clears it
+func (l LoginState) SetNotice(notice string, isError bool) LoginState {
+	l.notice = notice
+	l.noticeIsError = isError
+	return l
+}
+
+// Message of the day shown under the greeting
+func (l LoginState) SetMOTD(motd string) LoginState {
+	l.motd = motd
+	return l
+}
+
 func (l LoginState) SetFormError(err string) LoginState {
 	l.formError = err
 	l.activeElementId = roomInputId
@@ -78,25 +129,40 @@ func (l LoginState) Update(msg tea.Msg) (LoginState, tea.Cmd) {
 	var cmd tea.Cmd
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
-		if msg.Type == tea.KeyTab || msg.Type == tea.KeyShiftTab {
-			return l.focusNextFormElement(), cmd
+		km := l.keyMap
+		if l.showHelp {
+			l.showHelp = !key.Matches(msg, km.Global.Help, km.Global.CloseHelp)
+			return l, cmd
+		}
+		// Printable keys typed into the room id are text, not bindings
+		typing := l.activeElementId == roomInputId && len(msg.Runes) > 0 && !msg.Alt
+		if key.Matches(msg, km.Global.Help) && !typing {
+			l.showHelp = true
+			return l, cmd
+		}
+
+		if key.Matches(msg, km.Login.NextField, km.Login.PreviousField) {
+			return l.focusNextFormElement(key.Matches(msg, km.Login.PreviousField)), cmd
+		}
+
+		if l.activeElementId == accessibleToggleId {
+			if key.Matches(msg, km.Login.Toggle) {
+				l.accessible = !l.accessible
+				return l, createAccessibleModeToggledCmd(l.accessible)
+			}
+			return l, cmd
 		}
 
 		if l.activeElementId == buttonsId {
-			switch msg.String() {
-			case "left", "right", "h", "l":
+			switch {
+			case key.Matches(msg, km.Login.SwitchButton):
 				return l.focusNextButton(), cmd
-			case "enter":
+			case key.Matches(msg, km.Login.Submit):
 				switch l.activeButtonId {
 				case quitButtonId:
 					return l, tea.Quit
 				case loginButtonId:
-					roomId := l.roomTextInput.Value()
-					if roomId == "" {
-						l = l.SetFormError("room id empty")
-						return l, cmd
-					}
-					return l, createRoomJoinRequestCmd(roomId)
+					return l.submit()
 				}
 			default:
 				return l, cmd
@@ -104,26 +170,90 @@ func (l LoginState) Update(msg tea.Msg) (LoginState, tea.Cmd) {
 		}
 
 		if l.activeElementId == roomInputId {
-			if msg.Type == tea.KeyEnter {
-				return l.focusNextFormElement(), cmd
+			if key.Matches(msg, km.Login.Submit) {
+				// Plain mode has no visible buttons to tab to, join right away
+				if l.accessible {
+					return l.submit()
+				}
+				// Skip the toggle so Enter, Enter still joins
+				l.activeElementId = buttonsId
+				l.roomTextInput.Blur()
+				return l, cmd
 			}
 
 			l.roomTextInput, _ = l.roomTextInput.Update(msg)
 			return l, cmd
 		}
+
+	case tea.MouseMsg:
+		if msg.Type == tea.MouseLeft && !l.showHelp {
+			return l.click(msg.X, msg.Y)
+		}
 	}
 	return l, cmd
 }
 
+// Presses or focuses the part of the dialog at x, y
+func (l LoginState) click(x int, y int) (LoginState, tea.Cmd) {
+	_, areas, fits := l.dialog(l.terminalState, l.clientStyles)
+	if !fits {
+		return l, nil
+	}
+
+	switch {
+	case areas.roomInput.Contains(x, y):
+		l.activeElementId = roomInputId
+		l.roomTextInput.Focus()
+	case areas.toggle.Contains(x, y):
+		l.roomTextInput.Blur()
+		l.activeElementId = accessibleToggleId
+		l.accessible = !l.accessible
+		return l, createAccessibleModeToggledCmd(l.accessible)
+	case areas.quit.Contains(x, y):
+		return l, tea.Quit
+	case areas.join.Contains(x, y):
+		l.roomTextInput.Blur()
+		l.activeElementId = buttonsId
+		l.activeButtonId = loginButtonId
+		return l.submit()
+	}
+	return l, nil
+}
+
+func (l LoginState) submit() (LoginState, tea.Cmd) {
+	roomId := l.roomTextInput.Value()
+	if roomId == "" {
+		l = l.SetFormError("room id empty")
+		return l, nil
+	}
+	return l, createRoomJoinRequestCmd(roomId)
+}
+
 // Quick and dirty as form is simple
-func (l LoginState) focusNextFormElement() LoginState {
+func (l LoginState) focusNextFormElement(backwards bool) LoginState {
+	l.roomTextInput.Blur()
+
 	switch l.activeElementId {
 	case roomInputId:
-		l.activeElementId = buttonsId
-		l.roomTextInput.Blur()
+		if backwards {
+			l.activeElementId = buttonsId
+		} else {
+			l.activeElementId = accessibleToggleId
+		}
+	case accessibleToggleId:
+		if backwards {
+			l.activeElementId = roomInputId
+			l.roomTextInput.Focus()
+		} else {
+			l.activeElementId = buttonsId
+		}
 	case buttonsId:
-		l.activeElementId = roomInputId
-		l.roomTextInput.Focus()
+		if backwards {
+			l.activeElementId = accessibleToggleId
+		} else {
+			l.activeElementId = roomInputId
+			l.roomTextInput.Focus()
+		}
 	}
 	return l
 }
@@ -139,6 +269,28 @@ func (l LoginState) focusNextButton() LoginState {
 }
 
 func (l LoginState) Render(terminalState *terminal.TerminalState, styles *styles.ClientStyles) string {
+	if l.showHelp {
+		return renderHelp(l.keyMap, terminalState, styles)
+	}
+
+	box, _, fits := l.dialog(terminalState, styles)
+	if !fits {
+		return box
+	}
+
+	dialog := lipgloss.Place(terminalState.Width, terminalState.Height,
+		lipgloss.Center, lipgloss.Center,
+		box,
+		lipgloss.WithWhitespaceChars("|"),
+		lipgloss.WithWhitespaceForeground(styles.PlaceholderTxt.GetForeground()),
+	)
+
+	return dialog
+}
+
+// The largest dialog that fits the terminal along with where its parts end up
+// once centered. When none fits the box tells the terminal is too small.
+func (l LoginState) dialog(terminalState *terminal.TerminalState, styles *styles.ClientStyles) (string, dialogAreas, bool) {
 	buttonsAreFocused := l.activeElementId == buttonsId
 	quitButton := renderButton("Quit", buttonsAreFocused && l.activeButtonId == quitButtonId, styles)
 	okButton := renderButton("Join", buttonsAreFocused && l.activeButtonId == loginButtonId, styles)
@@ -153,17 +305,116 @@ func (l LoginState) Render(terminalState *terminal.TerminalState, styles *styles
 
 	logo := lipgloss.NewStyle().Width(40).Align(lipgloss.Center).MarginBottom(1).Foreground(styles.PrimaryColor).Render(consts.LOGO)
 	greeter := lipgloss.NewStyle().Width(40).Padding(0, 0, 1).Align(lipgloss.Center).Render(fmt.Sprintf("Welcome, %s!", userName))
+	if l.motd != "" {
+		motd := lipgloss.NewStyle().Width(40).Align(lipgloss.Center).Foreground(styles.MutedColor).Render(l.motd)
+		greeter = lipgloss.JoinVertical(lipgloss.Center, greeter, motd)
+	}
+	if l.notice != "" {
+		noticeColor := styles.PrimaryColor
+		if l.noticeIsError {
+			noticeColor = styles.ErrorColor
+		}
+		notice := lipgloss.NewStyle().Width(40).Align(lipgloss.Center).Foreground(noticeColor).Render(l.notice)
+		greeter = lipgloss.JoinVertical(lipgloss.Center, greeter, notice)
+	}
 	form := lipgloss.NewStyle().Padding(1, 0, 0).Render(renderTextInput("Room Id", l.roomTextInput, styles))
+	accessibleToggle := lipgloss.NewStyle().MarginBottom(1).Render(renderToggle("Plain text mode", l.accessible, l.activeElementId == accessibleToggleId, styles))
 	buttons := lipgloss.JoinHorizontal(lipgloss.Top, quitButton, "  ", okButton)
 
-	ui := lipgloss.JoinVertical(lipgloss.Center, logo, greeter, form, formError, buttons)
+	body := []string{greeter, form, formError, accessibleToggle, buttons}
+	hint := []string{}
+	if l.keyMap.Global.Help.Enabled() {
+		hint = append(hint, lipgloss.NewStyle().MarginTop(1).Foreground(styles.MutedColor).Render(l.keyMap.Global.Help.Help().Key+" key bindings"))
+	}
 
-	dialog := lipgloss.Place(terminalState.Width, terminalState.Height,
-		lipgloss.Center, lipgloss.Center,
-		styles.DialogBox.Render(ui),
-		lipgloss.WithWhitespaceChars("|"),
-		lipgloss.WithWhitespaceForeground(styles.PlaceholderTxt.GetForeground()),
-	)
+	// Short terminals lose the logo first, then the hint
+	dialogBox := func(sections ...string) string {
+		return styles.DialogBox.Render(lipgloss.JoinVertical(lipgloss.Center, sections...))
+	}
+	sections := slices.Concat([]string{logo}, body, hint)
+	if lipgloss.Height(dialogBox(sections...)) > terminalState.Height {
+		sections = slices.Concat(body, hint)
+	}
+	if lipgloss.Height(dialogBox(sections...)) > terminalState.Height {
+		sections = body
+	}
+	box := dialogBox(sections...)
+	if lipgloss.Height(box) > terminalState.Height || lipgloss.Width(box) > terminalState.Width {
+		smallest := dialogBox(body...)
+		return styles.RenderTooSmall(terminalState.Width, terminalState.Height, lipgloss.Width(smallest), lipgloss.Height(smallest)), dialogAreas{}, false
+	}
 
-	return dialog
+	// Mirrors how lipgloss centers the box on the screen and the sections in it
+	contentWidth := lipgloss.Width(lipgloss.JoinVertical(lipgloss.Center, sections...))
+	boxX := centerOffset(terminalState.Width-lipgloss.Width(box), false)
+	boxY := centerOffset(terminalState.Height-lipgloss.Height(box), false)
+	x := boxX + styles.DialogBox.GetBorderLeftSize() + styles.DialogBox.GetPaddingLeft()
+	y := boxY + styles.DialogBox.GetBorderTopSize() + styles.DialogBox.GetPaddingTop()
+
+	areas := dialogAreas{}
+	for _, section := range sections {
+		area := terminal.Area{
+			X:      x + centerOffset(contentWidth-lipgloss.Width(section), true),
+			Y:      y,
+			Width:  lipgloss.Width(section),
+			Height: lipgloss.Height(section),
+		}
+		switch section {
+		case form:
+			areas.roomInput = area
+		case accessibleToggle:
+			area.Height = 1
+			areas.toggle = area
+		case buttons:
+			areas.quit = terminal.Area{X: area.X, Y: area.Y, Width: lipgloss.Width(quitButton), Height: area.Height}
+			areas.join = terminal.Area{X: area.X + area.Width - lipgloss.Width(okButton), Y: area.Y, Width: lipgloss.Width(okButton), Height: area.Height}
+		}
+		y += lipgloss.Height(section)
+	}
+	return box, areas, true
+}
+
+// Cells left of something centered in gap extra cells, joined blocks round the
+// other way than placed ones
+func centerOffset(gap int, joined bool) int {
+	half := int(math.Round(float64(gap) * 0.5))
+	if joined {
+		return half
+	}
+	return gap - half
+}
+
+// Linear variant of Render for the accessible mode, the focused field is
+// always the last line so screen readers land on it
+func (l LoginState) RenderPlain() string {
+	view := strings.Builder{}
+	view.WriteString(fmt.Sprintf("Welcome, %s! Type a room id and press Enter to join. Tab switches fields, Ctrl+C quits.\n", l.userName))
+	if l.motd != "" {
+		view.WriteString("Message of the day: " + l.motd + "\n")
+	}
+	if l.notice != "" {
+		view.WriteString(l.notice + "\n")
+	}
+	if l.formError != "" {
+		view.WriteString(fmt.Sprintf("Error: %s\n", l.formError))
+	}
+
+	switch l.activeElementId {
+	case roomInputId:
+		view.WriteString("Room Id: " + l.roomTextInput.Value())
+	case accessibleToggleId:
+		state := "off"
+		if l.accessible {
+			state = "on"
+		}
+		view.WriteString(fmt.Sprintf("Plain text mode: %s (Enter toggles)", state))
+	case buttonsId:
+		label := "Join"
+		if l.activeButtonId == quitButtonId {
+			label = "Quit"
+		}
+		view.WriteString(fmt.Sprintf("Button: %s (Left/Right switches, Enter activates)", label))
+	}
+
+	return view.String()
 }
@@ -1,7 +1,9 @@
 package login
 
 import (
+	"github.com/NaiKiDEV/ssh-chat/internal/keymap"
 	"github.com/NaiKiDEV/ssh-chat/internal/styles"
+	"github.com/NaiKiDEV/ssh-chat/internal/terminal"
 	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/lipgloss"
 )
@@ -25,3 +27,34 @@ func renderTextInput(label string, ti textinput.Model, styles *styles.ClientStyl
 	input := lipgloss.JoinVertical(lipgloss.Top, styledLabel, ti.View())
 	return container.Render(input)
 }
+
+func renderToggle(label string, checked bool, focused bool, styles *styles.ClientStyles) string {
+	labelColor := styles.GreyColor
+	if focused {
+		labelColor = styles.PrimaryColor
+	}
+
+	checkbox := "[ ]"
+	if checked {
+		checkbox = "[x]"
+	}
+	return styles.RegularTxt.Foreground(labelColor).Bold(focused).Render(checkbox + " " + label)
+}
+
+func renderHelp(km *keymap.KeyMap, terminalState *terminal.TerminalState, styles *styles.ClientStyles) string {
+	title := styles.BoldRegularTxt.Foreground(styles.PrimaryColor).Render("Key bindings")
+	closeHint := ""
+	if km.Global.CloseHelp.Enabled() {
+		closeHint = styles.RegularTxt.Foreground(styles.MutedColor).Render(" " + keymap.FirstKey(km.Global.CloseHelp) + " close")
+	}
+	// Border and padding of the box
+	help := keymap.RenderHelp(km.LoginHelp(), terminalState.Width-6, styles)
+	box := styles.DialogBox.Padding(1, 2).Render(lipgloss.JoinVertical(lipgloss.Left, title+closeHint, "", help))
+
+	return lipgloss.Place(terminalState.Width, terminalState.Height,
+		lipgloss.Center, lipgloss.Center,
+		box,
+		lipgloss.WithWhitespaceChars("|"),
+		lipgloss.WithWhitespaceForeground(styles.PlaceholderTxt.GetForeground()),
+	)
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"slices"
+
+	"github.com/NaiKiDEV/ssh-chat/internal/config"
+	"github.com/NaiKiDEV/ssh-chat/internal/model"
+	"github.com/NaiKiDEV/ssh-chat/internal/webhook"
+	"github.com/charmbracelet/log"
+)
+
+// Forwards events of every room with configured webhooks to the dispatcher,
+// which queues them without blocking and dead-letters what it can't deliver
+func startWebhooks(dispatcher *webhook.Dispatcher, hooksByRoom map[string][]config.Webhook) {
+	for roomId, hooks := range hooksByRoom {
+		serverRoom, err := findRoom(roomId)
+		if err != nil {
+			log.Warn("Skipping webhooks", "error", err)
+			continue
+		}
+
+		serverRoom.Listen(func(event model.Event) {
+			for _, hook := range hooks {
+				if len(hook.Events) > 0 && !slices.Contains(hook.Events, event.Kind) {
+					continue
+				}
+				dispatcher.Dispatch(webhook.Endpoint{URL: hook.URL, Secret: hook.Secret}, event)
+			}
+		})
+		log.Info("Webhooks enabled", "room", roomId, "count", len(hooks))
+	}
+}